@@ -4,6 +4,7 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
+	"time"
 )
 
 type TwitchConfig struct {
@@ -18,6 +19,23 @@ type YoutubeConfig struct {
 	ClientSecret string `env:"YOUTUBE_CLIENT_SECRET"`
 	RedirectURI  string `env:"YOUTUBE_REDIRECT_URI"`
 	ConfigPath   string `env:"YOUTUBE_CONFIG_PATH"`
+
+	DailyQuota         int64                    `env:"YOUTUBE_DAILY_QUOTA" env-default:"10000"`
+	QuotaSlowdownRatio float64                  `env:"YOUTUBE_QUOTA_SLOWDOWN_RATIO" env-default:"0.8"`
+	SlowSyncInterval   time.Duration            `env:"YOUTUBE_SLOW_SYNC_INTERVAL" env-default:"6h"`
+	FeedBaseURL        string                   `env:"YOUTUBE_FEED_BASE_URL" env-default:"https://www.youtube.com/feeds/videos.xml"`
+	RequestsPerSecond  float64                  `env:"YOUTUBE_REQUESTS_PER_SECOND" env-default:"5"`
+	RequestBurst       int                      `env:"YOUTUBE_REQUEST_BURST" env-default:"10"`
+	SyncConcurrency    int                      `env:"YOUTUBE_SYNC_CONCURRENCY" env-default:"4"`
+	SyncRetries        int                      `env:"YOUTUBE_SYNC_RETRIES" env-default:"3"`
+	SyncRetryDelay     time.Duration            `env:"YOUTUBE_SYNC_RETRY_DELAY" env-default:"1s"`
+	BackfillVideos     int                      `env:"YOUTUBE_BACKFILL_VIDEOS" env-default:"25"`
+	RefreshInterval    time.Duration            `env:"YOUTUBE_REFRESH_INTERVAL" env-default:"6h"`
+	RefreshLookback    time.Duration            `env:"YOUTUBE_REFRESH_LOOKBACK" env-default:"720h"`
+	RefreshLimit       int                      `env:"YOUTUBE_REFRESH_LIMIT" env-default:"500"`
+	CacheTTLs          map[string]time.Duration `env:"YOUTUBE_CACHE_TTLS" env-separator:","`
+	CacheMaxEntries    int                      `env:"YOUTUBE_CACHE_MAX_ENTRIES" env-default:"10000"`
+	CachePruneInterval time.Duration            `env:"YOUTUBE_CACHE_PRUNE_INTERVAL" env-default:"10m"`
 }
 
 type HttpConfig struct {
@@ -36,12 +54,24 @@ type EsportConfig struct {
 	Teams   []string `env:"ESPORT_TEAMS" env-separator:","`
 }
 
+type ContentConfig struct {
+	Sources          []string                 `env:"CONTENT_SOURCES" env-separator:"," env-default:"youtube_history,twitch,youtube_live,youtube_premieres,youtube_watchlist,youtube_subscriptions,youtube_unsubscribe_channels,esport_events"`
+	Categories       map[string]string        `env:"CONTENT_CATEGORIES" env-separator:","`
+	ProviderTimeout  time.Duration            `env:"CONTENT_PROVIDER_TIMEOUT" env-default:"5s"`
+	ProviderTimeouts map[string]time.Duration `env:"CONTENT_PROVIDER_TIMEOUTS" env-separator:","`
+	Lookback         time.Duration            `env:"CONTENT_LOOKBACK" env-default:"168h"`
+	Lookbacks        map[string]time.Duration `env:"CONTENT_LOOKBACKS" env-separator:","`
+	WatchInterval    time.Duration            `env:"CONTENT_WATCH_INTERVAL" env-default:"1m"`
+	CacheTTL         time.Duration            `env:"CONTENT_CACHE_TTL" env-default:"30s"`
+}
+
 type Config struct {
 	Twitch  TwitchConfig
 	Http    HttpConfig
 	Zima    ZimaConfig
 	Youtube YoutubeConfig
 	Esport  EsportConfig
+	Content ContentConfig
 }
 
 func Init() (*Config, error) {
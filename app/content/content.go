@@ -4,9 +4,12 @@ import (
 	"content-oracle/app/database"
 	"content-oracle/app/providers"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-pkgz/syncs"
 	"log"
+	"net"
+	"time"
 )
 
 const MaxSuggestions = 20
@@ -17,108 +20,386 @@ type Artist struct {
 }
 
 type Content struct {
-	ID          string  `json:"id"`
-	Artist      Artist  `json:"artist"`
-	Title       string  `json:"title"`
-	Thumbnail   string  `json:"thumbnail"`
-	Url         string  `json:"url"`
-	IsLive      bool    `json:"isLive"`
-	Position    float64 `json:"position"`
-	Remaining   int     `json:"_"`
-	Category    string  `json:"category"`
-	PublishedAt string  `json:"publishedAt"`
+	ID          string   `json:"id"`
+	Artist      Artist   `json:"artist"`
+	Title       string   `json:"title"`
+	Thumbnail   string   `json:"thumbnail"`
+	Url         string   `json:"url"`
+	IsLive      bool     `json:"isLive"`
+	Position    float64  `json:"position"`
+	Remaining   int      `json:"_"`
+	Category    string   `json:"category"`
+	Categories  []string `json:"categories"`
+	PublishedAt string   `json:"publishedAt"`
+	Duration    int      `json:"duration"`
+	// ScheduledStartAt is when a live or upcoming broadcast starts.
+	ScheduledStartAt string `json:"scheduledStartAt,omitempty"`
+	Score            *Score `json:"score,omitempty"`
+}
+
+type Score struct {
+	Total       float64 `json:"total"`
+	ChannelRank float64 `json:"channelRank"`
+	Recency     float64 `json:"recency"`
+	Progress    float64 `json:"progress"`
+	Live        float64 `json:"live"`
+	Watchlist   float64 `json:"watchlist"`
 }
 
 type Provider interface {
-	GetAll(ignoredVideoIDs []string) ([]Content, error)
+	Name() string
+	GetAll(ctx context.Context, query Query) ([]Content, error)
+}
+
+const DefaultProviderTimeout = 5 * time.Second
+const DefaultLookback = 7 * 24 * time.Hour
+
+const (
+	SourceStatusOK       = "ok"
+	SourceStatusFailed   = "failed"
+	SourceStatusTimeout  = "timeout"
+	SourceStatusCanceled = "canceled"
+)
+
+type Source struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Count     int    `json:"count"`
+}
+
+type Result struct {
+	Content []Content
+	Sources []Source
+	Cursors map[string]string
+}
+
+type Page struct {
+	Content    []Content
+	NextCursor string
+}
+
+// PagedProvider is implemented by providers whose category can be loaded
+// page by page using the cursor returned with the previous page.
+type PagedProvider interface {
+	Provider
+	Category() string
+	GetPage(ctx context.Context, query Query) (Page, error)
+}
+
+var ErrUnknownCategory = errors.New("unknown category")
+
+type Timeouts struct {
+	Default   time.Duration
+	Providers map[string]time.Duration
+}
+
+func (t Timeouts) For(name string) time.Duration {
+	if timeout, ok := t.Providers[name]; ok && timeout > 0 {
+		return timeout
+	}
+
+	if t.Default > 0 {
+		return t.Default
+	}
+
+	return DefaultProviderTimeout
+}
+
+// Lookbacks is how far back in time each provider looks for content when the
+// request does not ask for a specific window.
+type Lookbacks struct {
+	Default   time.Duration
+	Providers map[string]time.Duration
+}
+
+func (l Lookbacks) For(name string) time.Duration {
+	if lookback, ok := l.Providers[name]; ok && lookback > 0 {
+		return lookback
+	}
+
+	if l.Default > 0 {
+		return l.Default
+	}
+
+	return DefaultLookback
 }
 
 type MultiProvider struct {
 	youtubeHistoryProvider *YouTubeHistory
 	providers              []Provider
+	timeouts               Timeouts
+	lookbacks              Lookbacks
 }
 
-func NewMultiProvider(zimaClient *providers.Zima, blockedVideoRepository *database.BlockedVideoRepository, providers ...Provider) MultiProvider {
-	youtubeHistoryProvider := NewYouTubeHistory(YouTubeHistoryOptions{
-		BlockedVideoRepository: blockedVideoRepository,
-		ZimaClient:             zimaClient,
-	})
+type MultiProviderOptions struct {
+	History   *YouTubeHistory
+	Providers []Provider
+	Timeouts  Timeouts
+	Lookbacks Lookbacks
+}
 
+func NewMultiProvider(opt MultiProviderOptions) MultiProvider {
 	return MultiProvider{
-		youtubeHistoryProvider: youtubeHistoryProvider,
-		providers:              providers,
+		youtubeHistoryProvider: opt.History,
+		providers:              opt.Providers,
+		timeouts:               opt.Timeouts,
+		lookbacks:              opt.Lookbacks,
 	}
 }
 
-func (mp MultiProvider) GetAll() ([]Content, error) {
-	allContent := make([]Content, 0)
-	historyContent, ignoredVideoIDs, err := mp.youtubeHistoryProvider.GetAll()
-	if err != nil {
-		log.Printf("[ERROR] failed to get content from youtube history: %s", err)
-		return nil, err
-	}
+func (mp MultiProvider) GetAll(ctx context.Context, query Query) Result {
+	historyContent, historySources, providerQuery := mp.getHistory(ctx, query)
 
-	allContent = append(allContent, historyContent...)
+	results := make([][]Content, len(mp.providers))
+	sources := make([]Source, len(mp.providers))
+	cursors := make([]string, len(mp.providers))
 	wg := syncs.NewSizedGroup(4)
 
-	for _, provider := range mp.providers {
-		wg.Go(func(ctx context.Context) {
-			content, err := provider.GetAll(ignoredVideoIDs)
-			if err != nil {
-				log.Printf("[ERROR] failed to get content from provider: %s", err)
-				return
-			}
-
-			allContent = append(allContent, content...)
+	for i, provider := range mp.providers {
+		wg.Go(func(_ context.Context) {
+			results[i], sources[i], cursors[i] = mp.getContent(ctx, provider, providerQuery)
 		})
 	}
 
 	wg.Wait()
 
-	return allContent, nil
+	allContent := make([]Content, 0)
+	allContent = append(allContent, historyContent...)
+	for _, content := range results {
+		allContent = append(allContent, content...)
+	}
+
+	nextCursors := make(map[string]string)
+	for i, provider := range mp.providers {
+		if pagedProvider, ok := provider.(PagedProvider); ok && cursors[i] != "" {
+			nextCursors[pagedProvider.Category()] = cursors[i]
+		}
+	}
+
+	return Result{
+		Content: Dedupe(allContent),
+		Sources: append(historySources, sources...),
+		Cursors: nextCursors,
+	}
+}
+
+// GetPage loads the next page of a single category. History is still fetched
+// so that watched videos stay excluded on every page.
+func (mp MultiProvider) GetPage(ctx context.Context, category string, query Query) (Result, error) {
+	var pagedProvider PagedProvider
+	for _, provider := range mp.providers {
+		if p, ok := provider.(PagedProvider); ok && p.Category() == category {
+			pagedProvider = p
+			break
+		}
+	}
+
+	if pagedProvider == nil {
+		return Result{}, ErrUnknownCategory
+	}
+
+	_, historySources, providerQuery := mp.getHistory(ctx, query)
+	content, source, cursor := mp.getContent(ctx, pagedProvider, providerQuery)
+
+	nextCursors := make(map[string]string)
+	if cursor != "" {
+		nextCursors[category] = cursor
+	}
+
+	return Result{
+		Content: Dedupe(content),
+		Sources: append(historySources, source),
+		Cursors: nextCursors,
+	}, nil
+}
+
+// getHistory returns no sources when history is disabled.
+func (mp MultiProvider) getHistory(ctx context.Context, query Query) ([]Content, []Source, Query) {
+	if mp.youtubeHistoryProvider == nil {
+		return nil, nil, query
+	}
+
+	historyIDsCh := make(chan []string, 1)
+	historyName := mp.youtubeHistoryProvider.Name()
+	historyContent, historySource := collect(ctx, historyName, mp.timeouts.For(historyName), func(ctx context.Context) ([]Content, error) {
+		content, historyIDs, err := mp.youtubeHistoryProvider.GetAll(ctx, mp.queryFor(historyName, query))
+		historyIDsCh <- historyIDs
+
+		return content, err
+	})
+
+	providerQuery := query
+	select {
+	case historyIDs := <-historyIDsCh:
+		providerQuery.IgnoredVideoIDs = append(historyIDs, query.IgnoredVideoIDs...)
+	default:
+	}
+
+	return historyContent, []Source{historySource}, providerQuery
+}
+
+func (mp MultiProvider) getContent(ctx context.Context, provider Provider, query Query) ([]Content, Source, string) {
+	cursorCh := make(chan string, 1)
+	content, source := collect(ctx, provider.Name(), mp.timeouts.For(provider.Name()), func(ctx context.Context) ([]Content, error) {
+		query := mp.queryFor(provider.Name(), query)
+
+		pagedProvider, ok := provider.(PagedProvider)
+		if !ok {
+			return provider.GetAll(ctx, query)
+		}
+
+		page, err := pagedProvider.GetPage(ctx, query)
+		cursorCh <- page.NextCursor
+
+		return page.Content, err
+	})
+
+	var cursor string
+	select {
+	case cursor = <-cursorCh:
+	default:
+	}
+
+	return content, source, cursor
+}
+
+func (mp MultiProvider) queryFor(name string, query Query) Query {
+	if query.Since.IsZero() {
+		query.Since = time.Now().Add(-mp.lookbacks.For(name))
+	}
+
+	return query
 }
 
 type ESportProvider interface {
-	GetAll() ([]providers.ESportMatch, error)
+	Name() string
+	GetAll(ctx context.Context) ([]providers.ESportMatch, error)
 }
 
-type MultiESportProvider []ESportProvider
+type MultiESportProvider struct {
+	providers []ESportProvider
+	timeouts  Timeouts
+}
 
-func (mp MultiESportProvider) GetAll() ([]providers.ESportMatch, error) {
-	wg := syncs.NewSizedGroup(4)
+type MultiESportProviderOptions struct {
+	Providers []ESportProvider
+	Timeouts  Timeouts
+}
 
-	var allMatches []providers.ESportMatch
+func NewMultiESportProvider(opt MultiESportProviderOptions) MultiESportProvider {
+	return MultiESportProvider{
+		providers: opt.Providers,
+		timeouts:  opt.Timeouts,
+	}
+}
 
-	for _, provider := range mp {
-		wg.Go(func(ctx context.Context) {
-			matches, err := provider.GetAll()
-			if err != nil {
-				log.Printf("[ERROR] failed to get matches from provider: %s", err)
-				return
-			}
+func (mp MultiESportProvider) GetAll(ctx context.Context) ([]providers.ESportMatch, []Source) {
+	results := make([][]providers.ESportMatch, len(mp.providers))
+	sources := make([]Source, len(mp.providers))
+	wg := syncs.NewSizedGroup(4)
 
-			allMatches = append(allMatches, matches...)
+	for i, provider := range mp.providers {
+		wg.Go(func(_ context.Context) {
+			results[i], sources[i] = collect(ctx, provider.Name(), mp.timeouts.For(provider.Name()), provider.GetAll)
 		})
 	}
 
 	wg.Wait()
 
-	return allMatches, nil
+	allMatches := make([]providers.ESportMatch, 0)
+	for _, matches := range results {
+		allMatches = append(allMatches, matches...)
+	}
+
+	return allMatches, sources
+}
+
+type collectResult[T any] struct {
+	items []T
+	err   error
+}
+
+// collect runs fn with its own deadline and gives up waiting once the deadline
+// passes or the parent context is canceled, so a provider that ignores its
+// context cannot hold up the rest of the response.
+func collect[T any](ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) ([]T, error)) ([]T, Source) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	resultCh := make(chan collectResult[T], 1)
+
+	go func() {
+		items, err := fn(ctx)
+		resultCh <- collectResult[T]{items: items, err: err}
+	}()
+
+	var result collectResult[T]
+	select {
+	case result = <-resultCh:
+	case <-ctx.Done():
+		result.err = ctx.Err()
+	}
+
+	source := Source{
+		Name:      name,
+		Status:    SourceStatusOK,
+		LatencyMs: time.Since(startedAt).Milliseconds(),
+		Count:     len(result.items),
+	}
+
+	if result.err != nil {
+		log.Printf("[ERROR] failed to get content from %s: %s", name, result.err)
+
+		source.Status = sourceErrorStatus(result.err)
+		source.Error = result.err.Error()
+		source.Count = 0
+
+		return nil, source
+	}
+
+	return result.items, source
+}
+
+func sourceErrorStatus(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return SourceStatusTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return SourceStatusCanceled
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return SourceStatusTimeout
+	}
+
+	return SourceStatusFailed
 }
 
 func YoutubeVideoToContent(v database.YouTubeVideo, category string) Content {
+	scheduledStartAt := ""
+	if v.ScheduledStartAt != nil {
+		scheduledStartAt = v.ScheduledStartAt.Local().String()
+	}
+
 	return Content{
 		ID: v.ID,
 		Artist: Artist{
 			Name: v.Channel.Title,
 			ID:   v.Channel.ID,
 		},
-		Title:       v.Title,
-		Thumbnail:   v.Thumbnail,
-		Url:         fmt.Sprintf("https://www.youtube.com/watch?v=%s", v.ID),
-		Category:    category,
-		PublishedAt: v.PublishedAt.Local().String(),
-		IsLive:      false,
-		Position:    0,
+		Title:            v.Title,
+		Thumbnail:        v.Thumbnail,
+		Url:              fmt.Sprintf("https://www.youtube.com/watch?v=%s", v.ID),
+		Category:         category,
+		PublishedAt:      v.PublishedAt.Local().String(),
+		Duration:         v.Duration,
+		IsLive:           v.ContentType == database.VideoContentTypeLive,
+		ScheduledStartAt: scheduledStartAt,
+		Position:         0,
 	}
 }
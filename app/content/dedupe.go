@@ -0,0 +1,75 @@
+package content
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Key returns an identifier that is the same for one piece of content no
+// matter which provider returned it.
+func (c Content) Key() string {
+	if videoID := youtubeVideoID(c.Url); videoID != "" {
+		return "youtube:" + videoID
+	}
+
+	if c.Url != "" {
+		return c.Url
+	}
+
+	return c.Category + ":" + c.ID
+}
+
+// Dedupe keeps the first occurrence of every content key and merges the
+// categories of later duplicates into it.
+func Dedupe(items []Content) []Content {
+	deduped := make([]Content, 0, len(items))
+	indexByKey := make(map[string]int, len(items))
+
+	for _, item := range items {
+		if len(item.Categories) == 0 {
+			item.Categories = []string{item.Category}
+		}
+
+		key := item.Key()
+		index, exists := indexByKey[key]
+		if !exists {
+			indexByKey[key] = len(deduped)
+			deduped = append(deduped, item)
+			continue
+		}
+
+		for _, category := range item.Categories {
+			if !slices.Contains(deduped[index].Categories, category) {
+				deduped[index].Categories = append(deduped[index].Categories, category)
+			}
+		}
+	}
+
+	return deduped
+}
+
+func youtubeVideoID(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch host {
+	case "youtu.be":
+		return strings.Trim(u.Path, "/")
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		if videoID := u.Query().Get("v"); videoID != "" {
+			return videoID
+		}
+
+		for _, prefix := range []string{"/shorts/", "/live/", "/embed/"} {
+			if videoID, ok := strings.CutPrefix(u.Path, prefix); ok {
+				return strings.Trim(videoID, "/")
+			}
+		}
+	}
+
+	return ""
+}
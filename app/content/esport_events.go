@@ -2,9 +2,18 @@ package content
 
 import (
 	"content-oracle/app/providers"
+	"context"
 	"log"
 )
 
+const ESportEventsProviderName = "esport_events"
+
+func init() {
+	RegisterESportProvider(ESportEventsProviderName, func(deps Dependencies, _ ProviderOptions) ESportProvider {
+		return NewESportEvents(deps.ESportClient)
+	})
+}
+
 type ESportEvents struct {
 	client *providers.ESport
 }
@@ -15,8 +24,12 @@ func NewESportEvents(client *providers.ESport) *ESportEvents {
 	}
 }
 
-func (c *ESportEvents) GetAll() ([]providers.ESportMatch, error) {
-	matches, err := c.client.GetMatches()
+func (c *ESportEvents) Name() string {
+	return ESportEventsProviderName
+}
+
+func (c *ESportEvents) GetAll(ctx context.Context) ([]providers.ESportMatch, error) {
+	matches, err := c.client.GetMatches(ctx)
 	if err != nil {
 		log.Printf("[ERROR] failed to get esport matches: %s", err)
 		return nil, err
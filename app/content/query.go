@@ -0,0 +1,111 @@
+package content
+
+import (
+	"content-oracle/app/database"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Query struct {
+	IgnoredVideoIDs []string
+	MinDuration     time.Duration
+	MaxDuration     time.Duration
+	FitsIn          time.Duration
+	Since           time.Time
+	Cursor          *database.VideoCursor
+	Limit           int
+	PerChannelLimit int
+}
+
+func (q Query) HasDurationFilter() bool {
+	return q.MinDuration > 0 || q.MaxDuration > 0 || q.FitsIn > 0
+}
+
+// MatchesDuration reports whether item passes the duration filters. Items with
+// an unknown duration, such as live streams, never match an active filter.
+// FitsIn is compared against the time left to watch, so a partially watched
+// video counts only its remaining part.
+func (q Query) MatchesDuration(item Content) bool {
+	if !q.HasDurationFilter() {
+		return true
+	}
+
+	if item.Duration <= 0 {
+		return false
+	}
+
+	duration := time.Duration(item.Duration) * time.Second
+	if q.MinDuration > 0 && duration < q.MinDuration {
+		return false
+	}
+
+	if q.MaxDuration > 0 && duration > q.MaxDuration {
+		return false
+	}
+
+	remaining := duration
+	if item.Remaining > 0 {
+		remaining = time.Duration(item.Remaining) * time.Second
+	}
+
+	if q.FitsIn > 0 && remaining > q.FitsIn {
+		return false
+	}
+
+	return true
+}
+
+// VideoFilter converts the query into repository filters. Stored videos have
+// not been started yet, so FitsIn narrows the maximum duration.
+func (q Query) VideoFilter(publishedAfter time.Time) database.VideoFilter {
+	maxDuration := q.MaxDuration
+	if q.FitsIn > 0 && (maxDuration == 0 || q.FitsIn < maxDuration) {
+		maxDuration = q.FitsIn
+	}
+
+	return database.VideoFilter{
+		PublishedAfter:  publishedAfter,
+		IgnoredVideoIDs: q.IgnoredVideoIDs,
+		MinDuration:     q.MinDuration,
+		MaxDuration:     maxDuration,
+		Cursor:          q.Cursor,
+		Limit:           q.Limit,
+		PerChannelLimit: q.PerChannelLimit,
+	}
+}
+
+// EncodeCursor turns a repository cursor into an opaque token for clients.
+func EncodeCursor(cursor *database.VideoCursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (*database.VideoCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor database.VideoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,183 @@
+package ranking
+
+import (
+	"content-oracle/app/content"
+	"content-oracle/app/database"
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const DefaultRecencyHalfLife = 72 * time.Hour
+
+type Weights struct {
+	ChannelRank float64
+	Recency     float64
+	Progress    float64
+	Live        float64
+	Watchlist   float64
+}
+
+var DefaultWeights = Weights{
+	ChannelRank: 3,
+	Recency:     2,
+	Progress:    2.5,
+	Live:        4,
+	Watchlist:   2.5,
+}
+
+var publishedAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+type Engine struct {
+	youtubeRepository          *database.YouTubeRepository
+	youtubeWatchlistRepository *database.YouTubeWatchlistRepository
+	weights                    Weights
+	recencyHalfLife            time.Duration
+}
+
+type EngineOptions struct {
+	YouTubeRepository          *database.YouTubeRepository
+	YouTubeWatchlistRepository *database.YouTubeWatchlistRepository
+	Weights                    Weights
+	RecencyHalfLife            time.Duration
+}
+
+func NewEngine(opt EngineOptions) *Engine {
+	weights := opt.Weights
+	if weights == (Weights{}) {
+		weights = DefaultWeights
+	}
+
+	recencyHalfLife := opt.RecencyHalfLife
+	if recencyHalfLife <= 0 {
+		recencyHalfLife = DefaultRecencyHalfLife
+	}
+
+	return &Engine{
+		youtubeRepository:          opt.YouTubeRepository,
+		youtubeWatchlistRepository: opt.YouTubeWatchlistRepository,
+		weights:                    weights,
+		recencyHalfLife:            recencyHalfLife,
+	}
+}
+
+type signals struct {
+	channelRanks map[string]int
+	maxRank      int
+	watchlist    map[string]struct{}
+	now          time.Time
+}
+
+// Rank scores every item and returns them as a single list ordered by total
+// score. Items with equal scores keep the order they were given in.
+func (e *Engine) Rank(ctx context.Context, items []content.Content) ([]content.Content, error) {
+	ranked, err := e.Score(ctx, items)
+	if err != nil {
+		return items, err
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score.Total > ranked[j].Score.Total
+	})
+
+	return ranked, nil
+}
+
+// Score attaches a score breakdown to every item without changing the order.
+func (e *Engine) Score(ctx context.Context, items []content.Content) ([]content.Content, error) {
+	s, err := e.loadSignals(ctx)
+	if err != nil {
+		return items, err
+	}
+
+	scored := make([]content.Content, len(items))
+	for i, item := range items {
+		score := e.score(item, s)
+		item.Score = &score
+		scored[i] = item
+	}
+
+	return scored, nil
+}
+
+func (e *Engine) loadSignals(ctx context.Context) (signals, error) {
+	s := signals{
+		channelRanks: make(map[string]int),
+		watchlist:    make(map[string]struct{}),
+		now:          time.Now(),
+	}
+
+	rankings, err := e.youtubeRepository.GetAllRanking()
+	if err != nil {
+		log.Printf("[ERROR] failed to get channel ranking: %s", err)
+		return s, err
+	}
+
+	for _, ranking := range rankings {
+		s.channelRanks[ranking.ID] = ranking.Rank
+		s.maxRank = max(s.maxRank, ranking.Rank)
+	}
+
+	videoIDs, err := e.youtubeWatchlistRepository.GetAllVideoIDs(ctx)
+	if err != nil {
+		log.Printf("[ERROR] failed to get watchlist video ids: %s", err)
+		return s, err
+	}
+
+	for _, id := range videoIDs {
+		s.watchlist[id] = struct{}{}
+	}
+
+	return s, nil
+}
+
+func (e *Engine) score(item content.Content, s signals) content.Score {
+	var score content.Score
+
+	if rank := s.channelRanks[item.Artist.ID]; rank > 0 && s.maxRank > 0 {
+		score.ChannelRank = e.weights.ChannelRank * float64(rank) / float64(s.maxRank)
+	}
+
+	if publishedAt, ok := parsePublishedAt(item.PublishedAt); ok {
+		age := max(s.now.Sub(publishedAt), 0)
+		score.Recency = e.weights.Recency * math.Exp2(-age.Hours()/e.recencyHalfLife.Hours())
+	}
+
+	if item.Position > 0 && item.Position < 100 {
+		score.Progress = e.weights.Progress * item.Position / 100
+	}
+
+	if item.IsLive {
+		score.Live = e.weights.Live
+	}
+
+	if _, ok := s.watchlist[item.ID]; ok {
+		score.Watchlist = e.weights.Watchlist
+	}
+
+	score.ChannelRank = round(score.ChannelRank)
+	score.Recency = round(score.Recency)
+	score.Progress = round(score.Progress)
+	score.Total = round(score.ChannelRank + score.Recency + score.Progress + score.Live + score.Watchlist)
+
+	return score
+}
+
+func parsePublishedAt(value string) (time.Time, bool) {
+	for _, layout := range publishedAtLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
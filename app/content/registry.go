@@ -0,0 +1,143 @@
+package content
+
+import (
+	"content-oracle/app/database"
+	"content-oracle/app/providers"
+	"fmt"
+	"sort"
+)
+
+// Dependencies are the shared clients and repositories provider factories
+// build their providers from.
+type Dependencies struct {
+	TwitchClient           *providers.Twitch
+	ZimaClient             *providers.Zima
+	ESportClient           *providers.ESport
+	YouTubeRepository      *database.YouTubeRepository
+	BlockedVideoRepository *database.BlockedVideoRepository
+}
+
+type ProviderOptions struct {
+	Category string
+}
+
+type ProviderFactory func(deps Dependencies, opt ProviderOptions) Provider
+
+type ESportProviderFactory func(deps Dependencies, opt ProviderOptions) ESportProvider
+
+var (
+	providerFactories       = make(map[string]ProviderFactory)
+	eSportProviderFactories = make(map[string]ESportProviderFactory)
+)
+
+// RegisterProvider makes a content provider available under name. It is meant
+// to be called from init and panics when the name is already taken.
+func RegisterProvider(name string, factory ProviderFactory) {
+	mustBeUnregistered(name)
+	providerFactories[name] = factory
+}
+
+// RegisterESportProvider makes an e-sport provider available under name. It
+// is meant to be called from init and panics when the name is already taken.
+func RegisterESportProvider(name string, factory ESportProviderFactory) {
+	mustBeUnregistered(name)
+	eSportProviderFactories[name] = factory
+}
+
+func mustBeUnregistered(name string) {
+	_, isProvider := providerFactories[name]
+	_, isESportProvider := eSportProviderFactories[name]
+	if isProvider || isESportProvider || name == YouTubeHistoryProviderName {
+		panic(fmt.Sprintf("content provider %q is already registered", name))
+	}
+}
+
+// RegisteredProviders returns the names of all known sources, including the
+// history source.
+func RegisteredProviders() []string {
+	names := []string{YouTubeHistoryProviderName}
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	for name := range eSportProviderFactories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Sources are the providers enabled by configuration, in configured order.
+type Sources struct {
+	History         *YouTubeHistory
+	Providers       []Provider
+	ESportProviders []ESportProvider
+}
+
+type SourcesOptions struct {
+	Enabled    []string
+	Categories map[string]string
+}
+
+// NewSources builds the enabled providers. History is not a regular provider
+// because the rest of the feed depends on it, so it is always fetched first
+// when enabled.
+func NewSources(deps Dependencies, opt SourcesOptions) (Sources, error) {
+	var sources Sources
+	seen := make(map[string]struct{}, len(opt.Enabled))
+
+	for _, name := range opt.Enabled {
+		if _, ok := seen[name]; ok {
+			return Sources{}, fmt.Errorf("content provider %q is enabled twice", name)
+		}
+		seen[name] = struct{}{}
+
+		providerOpt := ProviderOptions{
+			Category: opt.Categories[name],
+		}
+
+		if name == YouTubeHistoryProviderName {
+			sources.History = NewYouTubeHistory(YouTubeHistoryOptions{
+				BlockedVideoRepository: deps.BlockedVideoRepository,
+				ZimaClient:             deps.ZimaClient,
+				Category:               providerOpt.Category,
+			})
+			continue
+		}
+
+		if factory, ok := providerFactories[name]; ok {
+			sources.Providers = append(sources.Providers, factory(deps, providerOpt))
+			continue
+		}
+
+		if factory, ok := eSportProviderFactories[name]; ok {
+			sources.ESportProviders = append(sources.ESportProviders, factory(deps, providerOpt))
+			continue
+		}
+
+		return Sources{}, fmt.Errorf("unknown content provider %q, known providers: %v", name, RegisteredProviders())
+	}
+
+	return sources, nil
+}
+
+func (s Sources) Provider(name string) Provider {
+	for _, provider := range s.Providers {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+
+	return nil
+}
+
+func (s Sources) ESportProvider(name string) ESportProvider {
+	for _, provider := range s.ESportProviders {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+
+	return nil
+}
@@ -2,31 +2,66 @@ package content
 
 import (
 	"content-oracle/app/providers"
+	"context"
 	"fmt"
 	"strings"
 )
 
+const (
+	TwitchProviderName    = "twitch"
+	TwitchDefaultCategory = "Live Streams"
+)
+
+func init() {
+	RegisterProvider(TwitchProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewTwitch(TwitchOptions{
+			TwitchClient: deps.TwitchClient,
+			Category:     opt.Category,
+		})
+	})
+}
+
 type Twitch struct {
-	client *providers.Twitch
+	client   *providers.Twitch
+	category string
 }
 
 type TwitchOptions struct {
 	TwitchClient *providers.Twitch
+	Category     string
 }
 
 func NewTwitch(opt TwitchOptions) *Twitch {
+	category := opt.Category
+	if category == "" {
+		category = TwitchDefaultCategory
+	}
+
 	return &Twitch{
-		client: opt.TwitchClient,
+		client:   opt.TwitchClient,
+		category: category,
 	}
 }
 
-func (c *Twitch) GetAll(_ []string) ([]Content, error) {
+func (c *Twitch) Name() string {
+	return TwitchProviderName
+}
+
+func (c *Twitch) Category() string {
+	return c.category
+}
+
+func (c *Twitch) GetAll(_ context.Context, query Query) ([]Content, error) {
 	resp, err := c.client.GetLiveStreams()
 	if err != nil {
 		return nil, err
 	}
 
 	content := make([]Content, 0)
+	if query.HasDurationFilter() {
+		return content, nil
+	}
+
 	for _, stream := range resp.Data.Streams {
 		urlTemplate := stream.ThumbnailURL
 
@@ -41,10 +76,11 @@ func (c *Twitch) GetAll(_ []string) ([]Content, error) {
 			Artist: Artist{
 				Name: stream.UserName,
 			},
-			Thumbnail: url,
-			Url:       fmt.Sprintf("https://www.twitch.tv/%s", stream.UserLogin),
-			IsLive:    true,
-			Category:  "Live Streams",
+			Thumbnail:   url,
+			Url:         fmt.Sprintf("https://www.twitch.tv/%s", stream.UserLogin),
+			IsLive:      true,
+			Category:    c.category,
+			PublishedAt: stream.StartedAt.Local().String(),
 		})
 	}
 
@@ -0,0 +1,169 @@
+package content
+
+import (
+	"content-oracle/app/events"
+	"content-oracle/app/providers"
+	"context"
+	"log"
+	"time"
+)
+
+const DefaultWatchInterval = time.Minute
+
+// Watcher polls live sources and publishes what changed between two polls.
+type Watcher struct {
+	streamProvider Provider
+	esportProvider ESportProvider
+	broker         *events.Broker
+	interval       time.Duration
+	timeouts       Timeouts
+	streams        map[string]Content
+	matches        map[string]providers.ESportMatch
+}
+
+type WatcherOptions struct {
+	StreamProvider Provider
+	ESportProvider ESportProvider
+	Broker         *events.Broker
+	Interval       time.Duration
+	Timeouts       Timeouts
+}
+
+type ESportMatchChange struct {
+	Match    providers.ESportMatch `json:"match"`
+	Previous *ESportMatchSnapshot  `json:"previous,omitempty"`
+}
+
+type ESportMatchSnapshot struct {
+	IsLive bool   `json:"isLive"`
+	Score  string `json:"score"`
+}
+
+func NewWatcher(opt WatcherOptions) *Watcher {
+	interval := opt.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	return &Watcher{
+		streamProvider: opt.StreamProvider,
+		esportProvider: opt.ESportProvider,
+		broker:         opt.Broker,
+		interval:       interval,
+		timeouts:       opt.Timeouts,
+	}
+}
+
+func (w *Watcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.poll(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	if w.streamProvider != nil {
+		w.pollStreams(ctx)
+	}
+
+	if w.esportProvider != nil {
+		w.pollMatches(ctx)
+	}
+}
+
+func (w *Watcher) pollStreams(ctx context.Context) {
+	name := w.streamProvider.Name()
+	streams, source := collect(ctx, name, w.timeouts.For(name), func(ctx context.Context) ([]Content, error) {
+		return w.streamProvider.GetAll(ctx, Query{})
+	})
+	if source.Status != SourceStatusOK {
+		return
+	}
+
+	current := make(map[string]Content, len(streams))
+	for _, stream := range streams {
+		current[stream.Key()] = stream
+	}
+
+	previous := w.streams
+	w.streams = current
+
+	// The first poll only records the initial state.
+	if previous == nil {
+		return
+	}
+
+	started := make([]Content, 0)
+	for key, stream := range current {
+		if _, ok := previous[key]; !ok {
+			started = append(started, stream)
+		}
+	}
+
+	ended := make([]Content, 0)
+	for key, stream := range previous {
+		if _, ok := current[key]; !ok {
+			ended = append(ended, stream)
+		}
+	}
+
+	if len(started) > 0 {
+		log.Printf("[INFO] %d followed streams went live", len(started))
+		w.broker.Publish(events.TypeStreamsStarted, started)
+	}
+
+	if len(ended) > 0 {
+		log.Printf("[INFO] %d followed streams ended", len(ended))
+		w.broker.Publish(events.TypeStreamsEnded, ended)
+	}
+}
+
+func (w *Watcher) pollMatches(ctx context.Context) {
+	name := w.esportProvider.Name()
+	matches, source := collect(ctx, name, w.timeouts.For(name), w.esportProvider.GetAll)
+	if source.Status != SourceStatusOK {
+		return
+	}
+
+	current := make(map[string]providers.ESportMatch, len(matches))
+	for _, match := range matches {
+		current[match.Id] = match
+	}
+
+	previous := w.matches
+	w.matches = current
+
+	if previous == nil {
+		return
+	}
+
+	changes := make([]ESportMatchChange, 0)
+	for id, match := range current {
+		old, ok := previous[id]
+		if !ok {
+			changes = append(changes, ESportMatchChange{Match: match})
+			continue
+		}
+
+		if old.IsLive != match.IsLive || old.Score != match.Score {
+			changes = append(changes, ESportMatchChange{
+				Match:    match,
+				Previous: &ESportMatchSnapshot{IsLive: old.IsLive, Score: old.Score},
+			})
+		}
+	}
+
+	if len(changes) > 0 {
+		log.Printf("[INFO] %d esport matches changed", len(changes))
+		w.broker.Publish(events.TypeESportMatchesChanged, changes)
+	}
+}
@@ -3,6 +3,7 @@ package content
 import (
 	"content-oracle/app/database"
 	"content-oracle/app/providers"
+	"context"
 	"fmt"
 	"github.com/samber/lo"
 	"log"
@@ -15,26 +16,43 @@ import (
 const YoutubeApplicationName = "YouTube (com.google.ios.youtube)"
 const RemainingTimeThreshold = 300
 
+const (
+	YouTubeHistoryProviderName    = "youtube_history"
+	YouTubeHistoryDefaultCategory = "YouTube History"
+)
+
 type YouTubeHistory struct {
 	blockedVideoRepository *database.BlockedVideoRepository
 	zimaClient             *providers.Zima
+	category               string
 }
 
 type YouTubeHistoryOptions struct {
 	BlockedVideoRepository *database.BlockedVideoRepository
 	ZimaClient             *providers.Zima
+	Category               string
 }
 
 func NewYouTubeHistory(opt YouTubeHistoryOptions) *YouTubeHistory {
+	category := opt.Category
+	if category == "" {
+		category = YouTubeHistoryDefaultCategory
+	}
+
 	return &YouTubeHistory{
 		blockedVideoRepository: opt.BlockedVideoRepository,
 		zimaClient:             opt.ZimaClient,
+		category:               category,
 	}
 }
 
-func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
+func (y *YouTubeHistory) Name() string {
+	return YouTubeHistoryProviderName
+}
+
+func (y *YouTubeHistory) GetAll(ctx context.Context, query Query) ([]Content, []string, error) {
 	allHistoryIds := make([]string, 0)
-	history, err := y.zimaClient.GetContent(false, YoutubeApplicationName)
+	history, err := y.zimaClient.GetContent(ctx, false, YoutubeApplicationName)
 	if err != nil {
 		log.Printf("[ERROR] failed to get youtube history: %s", err)
 		return nil, allHistoryIds, err
@@ -48,7 +66,7 @@ func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
 		allHistoryIds = append(allHistoryIds, item.Metadata.VideoID)
 	}
 
-	blockedVideos, err := y.blockedVideoRepository.GetAll()
+	blockedVideos, err := y.blockedVideoRepository.GetAll(ctx)
 	if err != nil {
 		log.Printf("[ERROR] failed to get video activity: %s", err)
 		return nil, allHistoryIds, err
@@ -77,7 +95,7 @@ func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
 				continue
 			}
 
-			if time.Now().Sub(updatedAt) > 7*24*time.Hour {
+			if updatedAt.Before(query.Since) {
 				continue
 			}
 
@@ -89,9 +107,11 @@ func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
 
 		var playbackPosition float64
 		var remaining int
+		var duration int
 		if playbackInfo != nil {
 			playbackPosition = playbackInfo.Percentage
 			remaining = playbackInfo.TotalTime - playbackInfo.StartTime
+			duration = playbackInfo.TotalTime
 		}
 
 		content = append(content, Content{
@@ -103,8 +123,9 @@ func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
 			IsLive:      false,
 			Remaining:   remaining,
 			Position:    playbackPosition,
-			Category:    "YouTube History",
+			Category:    y.category,
 			PublishedAt: lastPlaybackAt,
+			Duration:    duration,
 		})
 	}
 
@@ -113,7 +134,7 @@ func (y *YouTubeHistory) GetAll() ([]Content, []string, error) {
 	})
 
 	content = lo.Filter(content, func(item Content, _ int) bool {
-		return item.Remaining > RemainingTimeThreshold
+		return item.Remaining > RemainingTimeThreshold && query.MatchesDuration(item)
 	})
 
 	return content, allHistoryIds, nil
@@ -0,0 +1,68 @@
+package content
+
+import (
+	"content-oracle/app/database"
+	"context"
+	"time"
+)
+
+const (
+	YouTubeLiveProviderName    = "youtube_live"
+	YouTubeLiveDefaultCategory = "YouTube Live"
+)
+
+func init() {
+	RegisterProvider(YouTubeLiveProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewYouTubeLive(YouTubeLiveOptions{
+			YoutubeRepository: deps.YouTubeRepository,
+			Category:          opt.Category,
+		})
+	})
+}
+
+type YouTubeLive struct {
+	youtubeRepository *database.YouTubeRepository
+	category          string
+}
+
+type YouTubeLiveOptions struct {
+	YoutubeRepository *database.YouTubeRepository
+	Category          string
+}
+
+func NewYouTubeLive(opt YouTubeLiveOptions) *YouTubeLive {
+	category := opt.Category
+	if category == "" {
+		category = YouTubeLiveDefaultCategory
+	}
+
+	return &YouTubeLive{
+		youtubeRepository: opt.YoutubeRepository,
+		category:          category,
+	}
+}
+
+func (y *YouTubeLive) Name() string {
+	return YouTubeLiveProviderName
+}
+
+func (y *YouTubeLive) Category() string {
+	return y.category
+}
+
+// GetAll returns the broadcasts of synced channels that are live now. The
+// lookback does not apply, a broadcast may have been scheduled long before.
+func (y *YouTubeLive) GetAll(ctx context.Context, query Query) ([]Content, error) {
+	content := make([]Content, 0)
+
+	videos, err := y.youtubeRepository.GetLiveVideos(ctx, query.VideoFilter(time.Time{}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, video := range videos {
+		content = append(content, YoutubeVideoToContent(video, y.category))
+	}
+
+	return content, nil
+}
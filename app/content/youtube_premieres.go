@@ -0,0 +1,68 @@
+package content
+
+import (
+	"content-oracle/app/database"
+	"context"
+	"time"
+)
+
+const (
+	YouTubePremieresProviderName    = "youtube_premieres"
+	YouTubePremieresDefaultCategory = "Premieres"
+)
+
+func init() {
+	RegisterProvider(YouTubePremieresProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewYouTubePremieres(YouTubePremieresOptions{
+			YoutubeRepository: deps.YouTubeRepository,
+			Category:          opt.Category,
+		})
+	})
+}
+
+type YouTubePremieres struct {
+	youtubeRepository *database.YouTubeRepository
+	category          string
+}
+
+type YouTubePremieresOptions struct {
+	YoutubeRepository *database.YouTubeRepository
+	Category          string
+}
+
+func NewYouTubePremieres(opt YouTubePremieresOptions) *YouTubePremieres {
+	category := opt.Category
+	if category == "" {
+		category = YouTubePremieresDefaultCategory
+	}
+
+	return &YouTubePremieres{
+		youtubeRepository: opt.YoutubeRepository,
+		category:          category,
+	}
+}
+
+func (y *YouTubePremieres) Name() string {
+	return YouTubePremieresProviderName
+}
+
+func (y *YouTubePremieres) Category() string {
+	return y.category
+}
+
+// GetAll returns the scheduled broadcasts and premieres of synced channels,
+// the soonest first.
+func (y *YouTubePremieres) GetAll(ctx context.Context, query Query) ([]Content, error) {
+	content := make([]Content, 0)
+
+	videos, err := y.youtubeRepository.GetUpcomingVideos(ctx, query.VideoFilter(time.Time{}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, video := range videos {
+		content = append(content, YoutubeVideoToContent(video, y.category))
+	}
+
+	return content, nil
+}
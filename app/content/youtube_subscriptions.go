@@ -2,35 +2,76 @@ package content
 
 import (
 	"content-oracle/app/database"
-	"time"
+	"context"
 )
 
+const (
+	YouTubeSubscriptionProviderName    = "youtube_subscriptions"
+	YouTubeSubscriptionDefaultCategory = "YouTube Suggestions"
+)
+
+func init() {
+	RegisterProvider(YouTubeSubscriptionProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewYouTubeSubscription(YouTubeSubscriptionOptions{
+			YoutubeRepository: deps.YouTubeRepository,
+			Category:          opt.Category,
+		})
+	})
+}
+
 type YouTubeSubscription struct {
 	youtubeRepository *database.YouTubeRepository
+	category          string
 }
 
 type YouTubeSubscriptionOptions struct {
 	YoutubeRepository *database.YouTubeRepository
+	Category          string
 }
 
 func NewYouTubeSubscription(opt YouTubeSubscriptionOptions) *YouTubeSubscription {
+	category := opt.Category
+	if category == "" {
+		category = YouTubeSubscriptionDefaultCategory
+	}
+
 	return &YouTubeSubscription{
 		youtubeRepository: opt.YoutubeRepository,
+		category:          category,
 	}
 }
 
-func (y *YouTubeSubscription) GetAll(ignoredVideoIDs []string) ([]Content, error) {
-	var content []Content
+func (y *YouTubeSubscription) Name() string {
+	return YouTubeSubscriptionProviderName
+}
 
-	publishedAfter := time.Now().AddDate(0, 0, -7)
-	videos, err := y.youtubeRepository.GetTopRankedChannelVideos(publishedAfter, ignoredVideoIDs)
+func (y *YouTubeSubscription) Category() string {
+	return y.category
+}
+
+func (y *YouTubeSubscription) GetAll(ctx context.Context, query Query) ([]Content, error) {
+	page, err := y.GetPage(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
+	return page.Content, nil
+}
+
+func (y *YouTubeSubscription) GetPage(ctx context.Context, query Query) (Page, error) {
+	content := make([]Content, 0)
+
+	videos, nextCursor, err := y.youtubeRepository.GetTopRankedChannelVideos(ctx, query.VideoFilter(query.Since))
+	if err != nil {
+		return Page{}, err
+	}
+
 	for _, video := range videos {
-		content = append(content, YoutubeVideoToContent(video, "YouTube Suggestions"))
+		content = append(content, YoutubeVideoToContent(video, y.Category()))
 	}
 
-	return content, nil
+	return Page{
+		Content:    content,
+		NextCursor: EncodeCursor(nextCursor),
+	}, nil
 }
@@ -2,35 +2,76 @@ package content
 
 import (
 	"content-oracle/app/database"
-	"time"
+	"context"
 )
 
+const (
+	YouTubeUnsubscribeChannelsProviderName    = "youtube_unsubscribe_channels"
+	YouTubeUnsubscribeChannelsDefaultCategory = "Unsubscribed Channels"
+)
+
+func init() {
+	RegisterProvider(YouTubeUnsubscribeChannelsProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewYouTubeUnsubscribeChannels(YouTubeUnsubscribeChannelsOptions{
+			YoutubeRepository: deps.YouTubeRepository,
+			Category:          opt.Category,
+		})
+	})
+}
+
 type YouTubeUnsubscribeChannels struct {
 	youtubeRepository *database.YouTubeRepository
+	category          string
 }
 
 type YouTubeUnsubscribeChannelsOptions struct {
 	YoutubeRepository *database.YouTubeRepository
+	Category          string
 }
 
 func NewYouTubeUnsubscribeChannels(opt YouTubeUnsubscribeChannelsOptions) *YouTubeUnsubscribeChannels {
+	category := opt.Category
+	if category == "" {
+		category = YouTubeUnsubscribeChannelsDefaultCategory
+	}
+
 	return &YouTubeUnsubscribeChannels{
 		youtubeRepository: opt.YoutubeRepository,
+		category:          category,
 	}
 }
 
-func (y *YouTubeUnsubscribeChannels) GetAll(ignoredVideoIDs []string) ([]Content, error) {
-	content := make([]Content, 0)
+func (y *YouTubeUnsubscribeChannels) Name() string {
+	return YouTubeUnsubscribeChannelsProviderName
+}
 
-	publishedAfter := time.Now().AddDate(0, 0, -7)
-	videos, err := y.youtubeRepository.GetLastVideosFromUnsubscribedChannels(publishedAfter, ignoredVideoIDs)
+func (y *YouTubeUnsubscribeChannels) Category() string {
+	return y.category
+}
+
+func (y *YouTubeUnsubscribeChannels) GetAll(ctx context.Context, query Query) ([]Content, error) {
+	page, err := y.GetPage(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
+	return page.Content, nil
+}
+
+func (y *YouTubeUnsubscribeChannels) GetPage(ctx context.Context, query Query) (Page, error) {
+	content := make([]Content, 0)
+
+	videos, nextCursor, err := y.youtubeRepository.GetLastVideosFromUnsubscribedChannels(ctx, query.VideoFilter(query.Since))
+	if err != nil {
+		return Page{}, err
+	}
+
 	for _, video := range videos {
-		content = append(content, YoutubeVideoToContent(video, "Unsubscribed Channels"))
+		content = append(content, YoutubeVideoToContent(video, y.Category()))
 	}
 
-	return content, nil
+	return Page{
+		Content:    content,
+		NextCursor: EncodeCursor(nextCursor),
+	}, nil
 }
@@ -2,28 +2,64 @@ package content
 
 import (
 	"content-oracle/app/database"
+	"context"
+	"time"
 )
 
+const (
+	YouTubeWatchlistProviderName    = "youtube_watchlist"
+	YouTubeWatchlistDefaultCategory = "YouTube Watchlist"
+)
+
+func init() {
+	RegisterProvider(YouTubeWatchlistProviderName, func(deps Dependencies, opt ProviderOptions) Provider {
+		return NewYouTubeWatchlist(YouTubeWatchlistOptions{
+			YoutubeRepository: deps.YouTubeRepository,
+			Category:          opt.Category,
+		})
+	})
+}
+
 type YouTubeWatchlist struct {
 	youtubeRepository *database.YouTubeRepository
+	category          string
+}
+
+type YouTubeWatchlistOptions struct {
+	YoutubeRepository *database.YouTubeRepository
+	Category          string
 }
 
-func NewYouTubeWatchlist(youtubeRepository *database.YouTubeRepository) *YouTubeWatchlist {
+func NewYouTubeWatchlist(opt YouTubeWatchlistOptions) *YouTubeWatchlist {
+	category := opt.Category
+	if category == "" {
+		category = YouTubeWatchlistDefaultCategory
+	}
+
 	return &YouTubeWatchlist{
-		youtubeRepository: youtubeRepository,
+		youtubeRepository: opt.YoutubeRepository,
+		category:          category,
 	}
 }
 
-func (y *YouTubeWatchlist) GetAll(ignoredVideoIDs []string) ([]Content, error) {
+func (y *YouTubeWatchlist) Name() string {
+	return YouTubeWatchlistProviderName
+}
+
+func (y *YouTubeWatchlist) Category() string {
+	return y.category
+}
+
+func (y *YouTubeWatchlist) GetAll(ctx context.Context, query Query) ([]Content, error) {
 	var content []Content
 
-	videos, err := y.youtubeRepository.GetWatchlistVideos(ignoredVideoIDs)
+	videos, err := y.youtubeRepository.GetWatchlistVideos(ctx, query.VideoFilter(time.Time{}))
 	if err != nil {
 		return nil, err
 	}
 
 	for _, video := range videos {
-		content = append(content, YoutubeVideoToContent(video, "YouTube Watchlist"))
+		content = append(content, YoutubeVideoToContent(video, y.category))
 	}
 
 	return content, nil
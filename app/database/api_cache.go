@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+const APICacheSchema = `
+	CREATE TABLE IF NOT EXISTS api_cache (
+		key TEXT PRIMARY KEY,
+		family TEXT NOT NULL,
+		value TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+const APICacheExpiresAtIndex = `CREATE INDEX IF NOT EXISTS api_cache_expires_at ON api_cache (expires_at)`
+
+type APICacheEntry struct {
+	Key       string    `json:"key" db:"key"`
+	Family    string    `json:"family" db:"family"`
+	Value     string    `json:"value" db:"value"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt string    `json:"createdAt" db:"created_at"`
+}
+
+type APICacheRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPICacheRepository(db *sqlx.DB) (*APICacheRepository, error) {
+	_, err := db.Exec(APICacheSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating api_cache table: %s", err)
+		return nil, err
+	}
+
+	_, err = db.Exec(APICacheExpiresAtIndex)
+	if err != nil {
+		log.Printf("[ERROR] Error creating api_cache index: %s", err)
+		return nil, err
+	}
+
+	return &APICacheRepository{db: db}, nil
+}
+
+// Get returns the entry stored under key unless it expired.
+func (a *APICacheRepository) Get(ctx context.Context, key string) (*APICacheEntry, error) {
+	var entry APICacheEntry
+	err := a.db.GetContext(ctx, &entry, "SELECT * FROM api_cache WHERE key = ? AND expires_at > ?", key, time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		log.Printf("[ERROR] Error getting api cache entry: %s", err)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (a *APICacheRepository) Set(ctx context.Context, entry APICacheEntry) error {
+	query := `
+		INSERT INTO api_cache (key, family, value, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			family = excluded.family,
+			value = excluded.value,
+			expires_at = excluded.expires_at,
+			created_at = CURRENT_TIMESTAMP
+	`
+	_, err := a.db.ExecContext(ctx, query, entry.Key, entry.Family, entry.Value, entry.ExpiresAt)
+	if err != nil {
+		log.Printf("[ERROR] Error saving api cache entry: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// Prune deletes expired entries and then, when more than maxEntries are left,
+// the ones closest to expiring. A maxEntries of zero keeps every entry that
+// did not expire.
+func (a *APICacheRepository) Prune(ctx context.Context, maxEntries int) error {
+	_, err := a.db.ExecContext(ctx, "DELETE FROM api_cache WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		log.Printf("[ERROR] Error deleting expired api cache entries: %s", err)
+		return err
+	}
+
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	query := `
+		DELETE FROM api_cache WHERE key IN (
+			SELECT key FROM api_cache
+			ORDER BY expires_at ASC
+			LIMIT MAX((SELECT COUNT(*) FROM api_cache) - ?, 0)
+		)
+	`
+	_, err = a.db.ExecContext(ctx, query, maxEntries)
+	if err != nil {
+		log.Printf("[ERROR] Error evicting api cache entries: %s", err)
+		return err
+	}
+
+	return nil
+}
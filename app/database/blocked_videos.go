@@ -1,6 +1,9 @@
 package database
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
 
 const BlockedVideosSchema = `
 	CREATE TABLE IF NOT EXISTS blocked_videos (
@@ -49,11 +52,11 @@ func (b *BlockedVideoRepository) Create(blockedVideo BlockedVideo) (*BlockedVide
 	return &blockedVideo, nil
 }
 
-func (b *BlockedVideoRepository) GetAll() ([]BlockedVideo, error) {
+func (b *BlockedVideoRepository) GetAll(ctx context.Context) ([]BlockedVideo, error) {
 	query := `SELECT * FROM blocked_videos`
 
 	var blockedVideos []BlockedVideo
-	err := b.db.Select(&blockedVideos, query)
+	err := b.db.SelectContext(ctx, &blockedVideos, query)
 	if err != nil {
 		return nil, err
 	}
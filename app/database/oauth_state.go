@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+const OAuthStateSchema = `
+	CREATE TABLE IF NOT EXISTS oauth_state (
+		state TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		code_verifier TEXT DEFAULT '',
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+const (
+	OAuthProviderYouTube = "youtube"
+	OAuthProviderTwitch  = "twitch"
+)
+
+// OAuthState is a pending authorization flow. CodeVerifier is the PKCE
+// verifier for providers that support it.
+type OAuthState struct {
+	State        string    `json:"state" db:"state"`
+	Provider     string    `json:"provider" db:"provider"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	ExpiresAt    time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt    string    `json:"createdAt" db:"created_at"`
+}
+
+type OAuthStateRepository struct {
+	db *sqlx.DB
+}
+
+func NewOAuthStateRepository(db *sqlx.DB) (*OAuthStateRepository, error) {
+	_, err := db.Exec(OAuthStateSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating oauth_state table: %s", err)
+		return nil, err
+	}
+
+	return &OAuthStateRepository{db: db}, nil
+}
+
+// Create stores a new flow and drops the flows that expired meanwhile.
+func (o *OAuthStateRepository) Create(ctx context.Context, state OAuthState) error {
+	_, err := o.db.ExecContext(ctx, "DELETE FROM oauth_state WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		log.Printf("[ERROR] Error deleting expired oauth states: %s", err)
+		return err
+	}
+
+	query := `INSERT INTO oauth_state (state, provider, code_verifier, expires_at) VALUES (?, ?, ?, ?)`
+	_, err = o.db.ExecContext(ctx, query, state.State, state.Provider, state.CodeVerifier, state.ExpiresAt)
+	if err != nil {
+		log.Printf("[ERROR] Error inserting oauth state: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// Consume deletes the flow so that a state can be used only once and returns
+// it, or nil when there is no such flow for the provider or it expired.
+func (o *OAuthStateRepository) Consume(ctx context.Context, state, provider string) (*OAuthState, error) {
+	var oauthState OAuthState
+	query := `DELETE FROM oauth_state WHERE state = ? AND provider = ? RETURNING *`
+	err := o.db.GetContext(ctx, &oauthState, query, state, provider)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		log.Printf("[ERROR] Error consuming oauth state: %s", err)
+		return nil, err
+	}
+
+	if oauthState.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+
+	return &oauthState, nil
+}
@@ -16,19 +16,25 @@ const SettingsSchema = `
 		id INTEGER PRIMARY KEY,
 		twitch_access_token TEXT DEFAULT '',
 		twitch_refresh_token TEXT DEFAULT '',
+		twitch_user_id TEXT DEFAULT '',
+		twitch_user_login TEXT DEFAULT '',
 		youtube_access_token TEXT DEFAULT '',
 		youtube_refresh_token TEXT DEFAULT '',
+		youtube_token_expiry TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP	
 	);
 `
 
 type Settings struct {
-	ID                  int    `json:"_" db:"id"`
-	TwitchAccessToken   string `json:"twitchAccessToken" db:"twitch_access_token"`
-	TwitchRefreshToken  string `json:"twitchRefreshToken" db:"twitch_refresh_token"`
-	YoutubeAccessToken  string `json:"youtubeAccessToken" db:"youtube_access_token"`
-	YoutubeRefreshToken string `json:"youtubeRefreshToken" db:"youtube_refresh_token"`
-	UpdatedAt           string `json:"updatedAt" db:"updated_at"`
+	ID                  int        `json:"_" db:"id"`
+	TwitchAccessToken   string     `json:"twitchAccessToken" db:"twitch_access_token"`
+	TwitchRefreshToken  string     `json:"twitchRefreshToken" db:"twitch_refresh_token"`
+	TwitchUserID        string     `json:"twitchUserId" db:"twitch_user_id"`
+	TwitchUserLogin     string     `json:"twitchUserLogin" db:"twitch_user_login"`
+	YoutubeAccessToken  string     `json:"youtubeAccessToken" db:"youtube_access_token"`
+	YoutubeRefreshToken string     `json:"youtubeRefreshToken" db:"youtube_refresh_token"`
+	YoutubeTokenExpiry  *time.Time `json:"youtubeTokenExpiry" db:"youtube_token_expiry"`
+	UpdatedAt           string     `json:"updatedAt" db:"updated_at"`
 }
 
 const DefaultSettingsID = 1
@@ -71,11 +77,21 @@ func (s *SettingsRepository) SetTwitchSettings(settings Settings) error {
 	return err
 }
 
+// SetTwitchUser stores the account the Twitch token belongs to.
+func (s *SettingsRepository) SetTwitchUser(settings Settings) error {
+	updateTime := time.Now().Format("2006-01-02 15:04:05")
+	query := `UPDATE settings SET twitch_user_id = ?, twitch_user_login = ?, updated_at = ? WHERE id = ?`
+
+	_, err := s.db.Exec(query, settings.TwitchUserID, settings.TwitchUserLogin, updateTime, DefaultSettingsID)
+
+	return err
+}
+
 func (s *SettingsRepository) SetYoutubeSettings(settings Settings) error {
 	updateTime := time.Now().Format("2006-01-02 15:04:05")
-	query := `UPDATE settings SET youtube_access_token = ?, youtube_refresh_token = ?, updated_at = ? WHERE id = ?`
+	query := `UPDATE settings SET youtube_access_token = ?, youtube_refresh_token = ?, youtube_token_expiry = ?, updated_at = ? WHERE id = ?`
 
-	_, err := s.db.Exec(query, settings.YoutubeAccessToken, settings.YoutubeRefreshToken, updateTime, DefaultSettingsID)
+	_, err := s.db.Exec(query, settings.YoutubeAccessToken, settings.YoutubeRefreshToken, settings.YoutubeTokenExpiry, updateTime, DefaultSettingsID)
 
 	return err
 }
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+const SyncRunSchema = `
+	CREATE TABLE IF NOT EXISTS sync_run (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		status TEXT NOT NULL,
+		triggered_by TEXT DEFAULT 'schedule',
+		channel_id TEXT DEFAULT '',
+		since TIMESTAMP,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP,
+		channels_scanned INTEGER DEFAULT 0,
+		videos_inserted INTEGER DEFAULT 0,
+		quota_units INTEGER DEFAULT 0,
+		error TEXT DEFAULT ''
+	)
+`
+
+const SyncRunErrorSchema = `
+	CREATE TABLE IF NOT EXISTS sync_run_error (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER NOT NULL,
+		channel_id TEXT DEFAULT '',
+		message TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (run_id) REFERENCES sync_run(id)
+	)
+`
+
+const (
+	SyncRunStatusRunning   = "running"
+	SyncRunStatusCompleted = "completed"
+	SyncRunStatusFailed    = "failed"
+)
+
+const (
+	SyncRunTriggerSchedule = "schedule"
+	SyncRunTriggerManual   = "manual"
+	SyncRunTriggerBackfill = "backfill"
+)
+
+type SyncRun struct {
+	ID              int            `json:"id" db:"id"`
+	Source          string         `json:"source" db:"source"`
+	Status          string         `json:"status" db:"status"`
+	TriggeredBy     string         `json:"triggeredBy" db:"triggered_by"`
+	ChannelID       string         `json:"channelId" db:"channel_id"`
+	Since           *time.Time     `json:"since" db:"since"`
+	StartedAt       time.Time      `json:"startedAt" db:"started_at"`
+	FinishedAt      *time.Time     `json:"finishedAt" db:"finished_at"`
+	ChannelsScanned int            `json:"channelsScanned" db:"channels_scanned"`
+	VideosInserted  int            `json:"videosInserted" db:"videos_inserted"`
+	QuotaUnits      int64          `json:"quotaUnits" db:"quota_units"`
+	Error           string         `json:"error" db:"error"`
+	Errors          []SyncRunError `json:"errors,omitempty" db:"-"`
+}
+
+type SyncRunError struct {
+	ID        int    `json:"id" db:"id"`
+	RunID     int    `json:"runId" db:"run_id"`
+	ChannelID string `json:"channelId" db:"channel_id"`
+	Message   string `json:"message" db:"message"`
+	CreatedAt string `json:"createdAt" db:"created_at"`
+}
+
+type SyncRunRepository struct {
+	db *sqlx.DB
+}
+
+func NewSyncRunRepository(db *sqlx.DB) (*SyncRunRepository, error) {
+	_, err := db.Exec(SyncRunSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating sync_run table: %s", err)
+		return nil, err
+	}
+
+	_, err = db.Exec(SyncRunErrorSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating sync_run_error table: %s", err)
+		return nil, err
+	}
+
+	return &SyncRunRepository{db: db}, nil
+}
+
+// Create stores a new running sync run. Source, TriggeredBy, ChannelID and
+// Since are taken from run.
+func (s *SyncRunRepository) Create(ctx context.Context, run SyncRun) (*SyncRun, error) {
+	run.Status = SyncRunStatusRunning
+	run.StartedAt = time.Now()
+
+	query := `INSERT INTO sync_run (source, status, triggered_by, channel_id, since, started_at) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := s.db.ExecContext(ctx, query, run.Source, run.Status, run.TriggeredBy, run.ChannelID, run.Since, run.StartedAt)
+	if err != nil {
+		log.Printf("[ERROR] Error inserting sync run: %s", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	run.ID = int(id)
+
+	return &run, nil
+}
+
+func (s *SyncRunRepository) Finish(ctx context.Context, run SyncRun) error {
+	query := `
+		UPDATE sync_run
+		SET status = ?, finished_at = ?, channels_scanned = ?, videos_inserted = ?, quota_units = ?, error = ?
+		WHERE id = ?
+	`
+	_, err := s.db.ExecContext(ctx, query, run.Status, run.FinishedAt, run.ChannelsScanned, run.VideosInserted, run.QuotaUnits, run.Error, run.ID)
+	if err != nil {
+		log.Printf("[ERROR] Error finishing sync run: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateProgress saves the counters of a run that is still in progress.
+func (s *SyncRunRepository) UpdateProgress(ctx context.Context, id, channelsScanned, videosInserted int) error {
+	query := `UPDATE sync_run SET channels_scanned = ?, videos_inserted = ? WHERE id = ?`
+	_, err := s.db.ExecContext(ctx, query, channelsScanned, videosInserted, id)
+	if err != nil {
+		log.Printf("[ERROR] Error updating sync run progress: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SyncRunRepository) CreateError(ctx context.Context, runError SyncRunError) error {
+	query := `INSERT INTO sync_run_error (run_id, channel_id, message) VALUES (?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, runError.RunID, runError.ChannelID, runError.Message)
+	if err != nil {
+		log.Printf("[ERROR] Error inserting sync run error: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAll returns the latest runs first, without their errors.
+func (s *SyncRunRepository) GetAll(ctx context.Context, limit int) ([]SyncRun, error) {
+	runs := make([]SyncRun, 0)
+	err := s.db.SelectContext(ctx, &runs, "SELECT * FROM sync_run ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		log.Printf("[ERROR] Error getting sync runs: %s", err)
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (s *SyncRunRepository) GetByID(ctx context.Context, id int) (*SyncRun, error) {
+	var run SyncRun
+	err := s.db.GetContext(ctx, &run, "SELECT * FROM sync_run WHERE id = ?", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		log.Printf("[ERROR] Error getting sync run by id: %s", err)
+		return nil, err
+	}
+
+	run.Errors = make([]SyncRunError, 0)
+	err = s.db.SelectContext(ctx, &run.Errors, "SELECT * FROM sync_run_error WHERE run_id = ? ORDER BY id", id)
+	if err != nil {
+		log.Printf("[ERROR] Error getting sync run errors: %s", err)
+		return nil, err
+	}
+
+	return &run, nil
+}
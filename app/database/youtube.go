@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -32,6 +33,11 @@ const YouTubeVideoSchema = `
 		url TEXT,
 		published_at TIMESTAMP,
 		is_shorts BOOLEAN DEFAULT FALSE,
+		duration INTEGER DEFAULT 0,
+		content_type TEXT DEFAULT 'video',
+		scheduled_start_at TIMESTAMP,
+		is_available BOOLEAN DEFAULT TRUE,
+		refreshed_at TIMESTAMP,
 		sync_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                     
         FOREIGN KEY (channel_id) REFERENCES youtube_channel(id)    	
 	)
@@ -44,6 +50,27 @@ const YouTubeRankingSchema = `
 	);
 `
 
+// Content types of stored videos. Live and upcoming broadcasts, premieres
+// included, are kept apart from regular uploads. Videos synced from channel
+// feeds are pending until their details are loaded by the refresh.
+const (
+	VideoContentTypeVideo       = "video"
+	VideoContentTypeLive        = "live"
+	VideoContentTypeUpcoming    = "upcoming"
+	VideoContentTypeMembersOnly = "members_only"
+	VideoContentTypePending     = "pending"
+)
+
+// YouTubeVideoChannelSchema maps videos seen in the watch history to their
+// channel, so each video is looked up through the Data API only once.
+const YouTubeVideoChannelSchema = `
+	CREATE TABLE IF NOT EXISTS youtube_video_channel (
+		video_id TEXT PRIMARY KEY,
+		channel_id TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
 type YouTubeChannel struct {
 	ID           string `json:"id" db:"id"`
 	Title        string `json:"title" db:"title"`
@@ -61,6 +88,14 @@ type YouTubeVideo struct {
 	PublishedAt time.Time      `json:"publishedAt" db:"published_at"`
 	SyncAt      string         `json:"syncAt" db:"sync_at"`
 	IsShorts    bool           `json:"isShorts" db:"is_shorts"`
+	Duration    int            `json:"duration" db:"duration"`
+	ContentType string         `json:"contentType" db:"content_type"`
+	// ScheduledStartAt is set for live and upcoming broadcasts.
+	ScheduledStartAt *time.Time `json:"scheduledStartAt" db:"scheduled_start_at"`
+	// IsAvailable is cleared once the video is deleted or made private.
+	IsAvailable bool       `json:"isAvailable" db:"is_available"`
+	RefreshedAt *time.Time `json:"refreshedAt" db:"refreshed_at"`
+	ChannelRank int        `json:"channelRank" db:"channel_rank"`
 }
 
 type YouTubeRanking struct {
@@ -87,6 +122,12 @@ func NewYoutubeRepository(db *sqlx.DB) (*YouTubeRepository, error) {
 		return nil, err
 	}
 
+	_, err = db.Exec(YouTubeVideoChannelSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating youtube_video_channel table: %s", err)
+		return nil, err
+	}
+
 	return &YouTubeRepository{db: db}, nil
 }
 
@@ -145,9 +186,62 @@ func (y *YouTubeRepository) CreateChannel(channel *YouTubeChannel) (*YouTubeChan
 	return channel, nil
 }
 
+func (y *YouTubeRepository) UpdateChannel(ctx context.Context, channel YouTubeChannel) error {
+	query := `UPDATE youtube_channel SET title = ?, preview_url = ?, is_subscribed = ? WHERE id = ?`
+	_, err := y.db.ExecContext(ctx, query, channel.Title, channel.PreviewURL, channel.IsSubscribed, channel.ID)
+	if err != nil {
+		log.Printf("[ERROR] Error updating channel: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetChannelIDByVideoID returns the channel of a stored or previously looked
+// up video, or an empty string when the video is unknown.
+func (y *YouTubeRepository) GetChannelIDByVideoID(ctx context.Context, videoID string) (string, error) {
+	var channelID string
+	query := `
+		SELECT channel_id FROM youtube_video WHERE id = ?
+		UNION ALL
+		SELECT channel_id FROM youtube_video_channel WHERE video_id = ?
+		LIMIT 1
+	`
+	err := y.db.GetContext(ctx, &channelID, query, videoID, videoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		log.Printf("[ERROR] Error getting channel id by video id: %s", err)
+		return "", err
+	}
+
+	return channelID, nil
+}
+
+func (y *YouTubeRepository) SaveVideoChannel(ctx context.Context, videoID, channelID string) error {
+	query := `
+		INSERT INTO youtube_video_channel (video_id, channel_id) VALUES (?, ?)
+		ON CONFLICT(video_id) DO UPDATE SET channel_id = excluded.channel_id
+	`
+	_, err := y.db.ExecContext(ctx, query, videoID, channelID)
+	if err != nil {
+		log.Printf("[ERROR] Error saving video channel: %s", err)
+		return err
+	}
+
+	return nil
+}
+
 func (y *YouTubeRepository) CreateVideo(video YouTubeVideo) error {
-	query := `INSERT INTO youtube_video (id, title, channel_id, thumbnail, url, published_at, is_shorts) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := y.db.Exec(query, video.ID, video.Title, video.ChannelID, video.Thumbnail, video.URL, video.PublishedAt, video.IsShorts)
+	contentType := video.ContentType
+	if contentType == "" {
+		contentType = VideoContentTypeVideo
+	}
+
+	query := `INSERT INTO youtube_video (id, title, channel_id, thumbnail, url, published_at, is_shorts, duration, content_type, scheduled_start_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := y.db.Exec(query, video.ID, video.Title, video.ChannelID, video.Thumbnail, video.URL, video.PublishedAt, video.IsShorts, video.Duration, contentType, video.ScheduledStartAt)
 	if err != nil {
 		log.Printf("[ERROR] Error inserting video: %s", err)
 		return err
@@ -171,8 +265,139 @@ func (y *YouTubeRepository) GetVideoByID(id string) (*YouTubeVideo, error) {
 	return &video, nil
 }
 
-func (y *YouTubeRepository) GetChannelVideos(channelID string, publishedAfter time.Time, ignoredVideoIDs []string) ([]YouTubeVideo, error) {
+// GetVideosToRefresh returns videos published after publishedAfter, including
+// unavailable ones as private or blocked videos can come back. Pending videos
+// come first, then the ones refreshed longest ago. Members-only videos are
+// left out as they cannot be loaded to refresh them.
+func (y *YouTubeRepository) GetVideosToRefresh(ctx context.Context, publishedAfter time.Time, limit int) ([]YouTubeVideo, error) {
+	videos := make([]YouTubeVideo, 0)
+	query := `
+		SELECT * FROM youtube_video
+		WHERE content_type != ?
+		  AND published_at > ?
+		ORDER BY content_type = ? DESC, COALESCE(refreshed_at, sync_at) ASC
+		LIMIT ?
+	`
+	err := y.db.SelectContext(ctx, &videos, query, VideoContentTypeMembersOnly, publishedAfter, VideoContentTypePending, limit)
+	if err != nil {
+		log.Printf("[ERROR] Error getting videos to refresh: %s", err)
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// UpdateVideo stores refreshed metadata of an available video.
+func (y *YouTubeRepository) UpdateVideo(ctx context.Context, video YouTubeVideo) error {
+	query := `
+		UPDATE youtube_video
+		SET title = ?, thumbnail = ?, is_shorts = ?, duration = ?, content_type = ?, scheduled_start_at = ?,
+			is_available = TRUE, refreshed_at = ?
+		WHERE id = ?
+	`
+	_, err := y.db.ExecContext(ctx, query, video.Title, video.Thumbnail, video.IsShorts, video.Duration,
+		video.ContentType, video.ScheduledStartAt, time.Now(), video.ID)
+	if err != nil {
+		log.Printf("[ERROR] Error updating video: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (y *YouTubeRepository) MarkVideoUnavailable(ctx context.Context, id string) error {
+	query := `UPDATE youtube_video SET is_available = FALSE, refreshed_at = ? WHERE id = ?`
+	_, err := y.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		log.Printf("[ERROR] Error marking video unavailable: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+type VideoFilter struct {
+	PublishedAfter  time.Time
+	IgnoredVideoIDs []string
+	MinDuration     time.Duration
+	MaxDuration     time.Duration
+	Cursor          *VideoCursor
+	Limit           int
+	PerChannelLimit int
+}
+
+// VideoCursor marks the last video of a page. Rank is only used by queries
+// ordered by channel ranking.
+type VideoCursor struct {
+	Rank        int       `json:"r,omitempty"`
+	PublishedAt time.Time `json:"p"`
+	ID          string    `json:"i"`
+}
+
+func (f VideoFilter) limits() (int, int) {
+	perChannelLimit := f.PerChannelLimit
+	if perChannelLimit <= 0 {
+		perChannelLimit = MaxVideosFromChannel
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = TotalAmountOfVideos
+	}
+
+	return perChannelLimit, limit
+}
+
+func nextVideoCursor(videos []YouTubeVideo, limit int) *VideoCursor {
+	if len(videos) == 0 || len(videos) < limit {
+		return nil
+	}
+
+	last := videos[len(videos)-1]
+
+	return &VideoCursor{
+		Rank:        last.ChannelRank,
+		PublishedAt: last.PublishedAt,
+		ID:          last.ID,
+	}
+}
+
+func (f VideoFilter) conditions() (string, []interface{}) {
+	query := ""
+	args := make([]interface{}, 0)
+
+	if !f.PublishedAfter.IsZero() {
+		query += " AND v.published_at > ?"
+		args = append(args, f.PublishedAfter)
+	}
+
+	if len(f.IgnoredVideoIDs) > 0 {
+		placeholders := make([]string, len(f.IgnoredVideoIDs))
+		for i, id := range f.IgnoredVideoIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+
+		query += fmt.Sprintf(" AND v.id NOT IN (%s)", strings.Join(placeholders, ","))
+	}
+
+	if f.MinDuration > 0 {
+		query += " AND v.duration >= ?"
+		args = append(args, int(f.MinDuration.Seconds()))
+	}
+
+	if f.MaxDuration > 0 {
+		query += " AND v.duration > 0 AND v.duration <= ?"
+		args = append(args, int(f.MaxDuration.Seconds()))
+	}
+
+	return query, args
+}
+
+func (y *YouTubeRepository) GetChannelVideos(ctx context.Context, channelID string, filter VideoFilter) ([]YouTubeVideo, error) {
 	videos := make([]YouTubeVideo, 0)
+	conditions, conditionArgs := filter.conditions()
+
 	query := `  SELECT 
       					v.id as id,
       					v.title as title,
@@ -181,6 +406,7 @@ func (y *YouTubeRepository) GetChannelVideos(channelID string, publishedAfter ti
       					v.url as url,
       					v.published_at as published_at,
       					v.is_shorts as is_shorts,
+      					v.duration as duration,
       					v.sync_at as sync_at,
       					c.id as "channel.id",
       					c.title as "channel.title",
@@ -191,27 +417,16 @@ func (y *YouTubeRepository) GetChannelVideos(channelID string, publishedAfter ti
 					LEFT JOIN blocked_channels bc ON c.id = bc.channel_id
         			LEFT JOIN blocked_videos bv ON v.id = bv.video_id
 				WHERE v.channel_id = ? 
-					AND v.published_at > ? 
 					AND v.is_shorts = FALSE
+					AND v.content_type = 'video'
+					AND v.is_available = TRUE
 					AND bc.channel_id IS NULL
 					AND bv.video_id IS NULL
-	`
+	` + conditions
 
-	if len(ignoredVideoIDs) > 0 {
-		placeholders := make([]string, len(ignoredVideoIDs))
-		for i := range ignoredVideoIDs {
-			placeholders[i] = "?"
-		}
-
-		query += fmt.Sprintf(" AND v.id NOT IN (%s)", strings.Join(placeholders, ","))
-	}
+	args := append([]interface{}{channelID}, conditionArgs...)
 
-	args := []interface{}{channelID, publishedAfter}
-	for _, id := range ignoredVideoIDs {
-		args = append(args, id)
-	}
-
-	err := y.db.Select(&videos, query, args...)
+	err := y.db.SelectContext(ctx, &videos, query, args...)
 	if err != nil {
 		log.Printf("[ERROR] Error getting channel videos: %s", err)
 		return nil, err
@@ -223,13 +438,18 @@ func (y *YouTubeRepository) GetChannelVideos(channelID string, publishedAfter ti
 const MaxVideosFromChannel = 3
 const TotalAmountOfVideos = 20
 
-func (y *YouTubeRepository) GetTopRankedChannelVideos(publishedAfter time.Time, ignoredVideoIDs []string) ([]YouTubeVideo, error) {
+func (y *YouTubeRepository) GetTopRankedChannelVideos(ctx context.Context, filter VideoFilter) ([]YouTubeVideo, *VideoCursor, error) {
 	videos := make([]YouTubeVideo, 0)
-
-	placeholders := make([]string, len(ignoredVideoIDs))
-	for i := range ignoredVideoIDs {
-		placeholders[i] = "?"
+	conditions, args := filter.conditions()
+	perChannelLimit, limit := filter.limits()
+	args = append(args, perChannelLimit)
+
+	cursorCondition := ""
+	if c := filter.Cursor; c != nil {
+		cursorCondition = `AND (q.rank < ? OR (q.rank = ? AND (q.vidoe_published_at < ? OR (q.vidoe_published_at = ? AND q.video_id < ?))))`
+		args = append(args, c.Rank, c.Rank, c.PublishedAt, c.PublishedAt, c.ID)
 	}
+	args = append(args, limit)
 
 	query := fmt.Sprintf(`
 		SELECT q.video_id              as id,
@@ -238,7 +458,9 @@ func (y *YouTubeRepository) GetTopRankedChannelVideos(publishedAfter time.Time,
 			   q.vidoe_url             as url,
 			   q.vidoe_published_at    as published_at,
 			   q.vidoe_is_shorts       as is_shorts,
+			   q.video_duration        as duration,
 			   q.vidoe_sync_at         as sync_at,
+			   q.rank                  as channel_rank,
 			   q.channel_id            as "channel.id",
 			   q.channel_title         as "channel.title",
 			   q.channel_preview_url   as "channel.preview_url",
@@ -249,6 +471,7 @@ func (y *YouTubeRepository) GetTopRankedChannelVideos(publishedAfter time.Time,
 					 v.url                                                              as "vidoe_url",
 					 v.published_at                                                     as "vidoe_published_at",
 					 v.is_shorts                                                        as "vidoe_is_shorts",
+					 v.duration                                                         as "video_duration",
 					 v.sync_at                                                          as "vidoe_sync_at",
 					 c.id                                                               as "channel_id",
 					 c.title                                                            as "channel_title",
@@ -262,37 +485,38 @@ func (y *YouTubeRepository) GetTopRankedChannelVideos(publishedAfter time.Time,
 					   LEFT JOIN blocked_channels bc ON c.id = bc.channel_id
 					   LEFT JOIN blocked_videos bv ON v.id = bv.video_id
 			  WHERE v.is_shorts = FALSE
-			    AND v.published_at > ?
+				AND v.content_type = 'video'
+				AND v.is_available = TRUE
 				AND bc.channel_id IS NULL
 				AND bv.video_id IS NULL
-			  	AND v.id NOT IN (%s)) q
+				%s) q
 		WHERE row_num <= ?
-		ORDER BY q.rank DESC, published_at DESC
+		  %s
+		ORDER BY q.rank DESC, published_at DESC, id DESC
 		LIMIT ?;
-	`, strings.Join(placeholders, ","))
-
-	args := []interface{}{publishedAfter}
-	for _, id := range ignoredVideoIDs {
-		args = append(args, id)
-	}
-	args = append(args, MaxVideosFromChannel, TotalAmountOfVideos)
+	`, conditions, cursorCondition)
 
-	err := y.db.Select(&videos, query, args...)
+	err := y.db.SelectContext(ctx, &videos, query, args...)
 	if err != nil {
 		log.Printf("[ERROR] Error getting top ranked channel videos: %s", err)
-		return videos, err
+		return videos, nil, err
 	}
 
-	return videos, nil
+	return videos, nextVideoCursor(videos, limit), nil
 }
 
-func (y *YouTubeRepository) GetLastVideosFromUnsubscribedChannels(publishedAfter time.Time, ignoredVideoIDs []string) ([]YouTubeVideo, error) {
+func (y *YouTubeRepository) GetLastVideosFromUnsubscribedChannels(ctx context.Context, filter VideoFilter) ([]YouTubeVideo, *VideoCursor, error) {
 	videos := make([]YouTubeVideo, 0)
-
-	placeholders := make([]string, len(ignoredVideoIDs))
-	for i := range ignoredVideoIDs {
-		placeholders[i] = "?"
+	conditions, args := filter.conditions()
+	perChannelLimit, limit := filter.limits()
+	args = append(args, perChannelLimit)
+
+	cursorCondition := ""
+	if c := filter.Cursor; c != nil {
+		cursorCondition = `AND (q.vidoe_published_at < ? OR (q.vidoe_published_at = ? AND q.video_id < ?))`
+		args = append(args, c.PublishedAt, c.PublishedAt, c.ID)
 	}
+	args = append(args, limit)
 
 	query := fmt.Sprintf(`
 		SELECT q.video_id              as id,
@@ -301,6 +525,7 @@ func (y *YouTubeRepository) GetLastVideosFromUnsubscribedChannels(publishedAfter
 			   q.vidoe_url             as url,
 			   q.vidoe_published_at    as published_at,
 			   q.vidoe_is_shorts       as is_shorts,
+			   q.video_duration        as duration,
 			   q.vidoe_sync_at         as sync_at,
 			   q.channel_id            as "channel.id",
 			   q.channel_title         as "channel.title",
@@ -312,6 +537,7 @@ func (y *YouTubeRepository) GetLastVideosFromUnsubscribedChannels(publishedAfter
 					 v.url                                                              as "vidoe_url",
 					 v.published_at                                                     as "vidoe_published_at",
 					 v.is_shorts                                                        as "vidoe_is_shorts",
+					 v.duration                                                         as "video_duration",
 					 v.sync_at                                                          as "vidoe_sync_at",
 					 c.id                                                               as "channel_id",
 					 c.title                                                            as "channel_title",
@@ -323,38 +549,30 @@ func (y *YouTubeRepository) GetLastVideosFromUnsubscribedChannels(publishedAfter
 					   LEFT JOIN blocked_channels bc ON c.id = bc.channel_id
 					   LEFT JOIN blocked_videos bv ON v.id = bv.video_id
 			  WHERE c.is_subscribed = FALSE
-			    AND v.published_at > ?
 				AND v.is_shorts = FALSE
+				AND v.content_type = 'video'
+				AND v.is_available = TRUE
 				AND bc.channel_id IS NULL
 				AND bv.video_id IS NULL
-			  	AND v.id NOT IN (%s)) q
+				%s) q
 		WHERE row_num <= ?
-		ORDER BY published_at DESC
+		  %s
+		ORDER BY published_at DESC, id DESC
 		LIMIT ?;
-	`, strings.Join(placeholders, ","))
+	`, conditions, cursorCondition)
 
-	args := []interface{}{publishedAfter}
-	for _, id := range ignoredVideoIDs {
-		args = append(args, id)
-	}
-	args = append(args, MaxVideosFromChannel, TotalAmountOfVideos)
-
-	err := y.db.Select(&videos, query, args...)
+	err := y.db.SelectContext(ctx, &videos, query, args...)
 	if err != nil {
 		log.Printf("[ERROR] Error getting top ranked channel videos: %s", err)
-		return videos, err
+		return videos, nil, err
 	}
 
-	return videos, nil
+	return videos, nextVideoCursor(videos, limit), nil
 }
 
-func (y *YouTubeRepository) GetWatchlistVideos(ignoredVideoIDs []string) ([]YouTubeVideo, error) {
+func (y *YouTubeRepository) GetWatchlistVideos(ctx context.Context, filter VideoFilter) ([]YouTubeVideo, error) {
 	videos := make([]YouTubeVideo, 0)
-
-	placeholders := make([]string, len(ignoredVideoIDs))
-	for i := range ignoredVideoIDs {
-		placeholders[i] = "?"
-	}
+	conditions, args := filter.conditions()
 
 	query := fmt.Sprintf(`
 		SELECT v.id            as id,
@@ -363,6 +581,7 @@ func (y *YouTubeRepository) GetWatchlistVideos(ignoredVideoIDs []string) ([]YouT
 			   v.url           as url,
 			   v.published_at  as published_at,
 			   v.is_shorts     as is_shorts,
+			   v.duration      as duration,
 			   v.sync_at       as sync_at,
 			   c.id            as "channel.id",
 			   c.title         as "channel.title",
@@ -374,19 +593,79 @@ func (y *YouTubeRepository) GetWatchlistVideos(ignoredVideoIDs []string) ([]YouT
 				 LEFT JOIN blocked_channels bc ON c.id = bc.channel_id
 				 LEFT JOIN blocked_videos bv ON v.id = bv.video_id
 		WHERE yw.id IS NOT NULL
+		  AND v.is_available = TRUE
 		  AND bc.channel_id IS NULL
 		  AND bv.video_id IS NULL
-		  AND v.id NOT IN (%s)
-	`, strings.Join(placeholders, ","))
+		  %s
+	`, conditions)
 
-	args := make([]interface{}, 0)
-	for _, id := range ignoredVideoIDs {
-		args = append(args, id)
+	err := y.db.SelectContext(ctx, &videos, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Error getting top ranked channel videos: %s", err)
+		return videos, err
 	}
 
-	err := y.db.Select(&videos, query, args...)
+	return videos, nil
+}
+
+// MaxLiveDuration is how long after its start a broadcast is still shown as
+// live. Broadcasts that ended are only noticed when their video is synced
+// again.
+const MaxLiveDuration = 12 * time.Hour
+
+// GetLiveVideos returns the broadcasts that are live now, latest started first.
+func (y *YouTubeRepository) GetLiveVideos(ctx context.Context, filter VideoFilter) ([]YouTubeVideo, error) {
+	startedAfter := time.Now().Add(-MaxLiveDuration)
+	return y.getBroadcastVideos(ctx, VideoContentTypeLive, startedAfter, "DESC", filter)
+}
+
+// GetUpcomingVideos returns the scheduled broadcasts and premieres that did not
+// start yet, the soonest first.
+func (y *YouTubeRepository) GetUpcomingVideos(ctx context.Context, filter VideoFilter) ([]YouTubeVideo, error) {
+	return y.getBroadcastVideos(ctx, VideoContentTypeUpcoming, time.Now(), "ASC", filter)
+}
+
+func (y *YouTubeRepository) getBroadcastVideos(ctx context.Context, contentType string, startsAfter time.Time, order string, filter VideoFilter) ([]YouTubeVideo, error) {
+	videos := make([]YouTubeVideo, 0)
+	conditions, conditionArgs := filter.conditions()
+	_, limit := filter.limits()
+
+	query := fmt.Sprintf(`
+		SELECT v.id                 as id,
+			   v.title              as title,
+			   v.thumbnail          as thumbnail,
+			   v.channel_id         as channel_id,
+			   v.url                as url,
+			   v.published_at       as published_at,
+			   v.is_shorts          as is_shorts,
+			   v.duration           as duration,
+			   v.content_type       as content_type,
+			   v.scheduled_start_at as scheduled_start_at,
+			   v.sync_at            as sync_at,
+			   c.id                 as "channel.id",
+			   c.title              as "channel.title",
+			   c.preview_url        as "channel.preview_url",
+			   c.is_subscribed      as "channel.is_subscribed"
+		FROM youtube_video v
+				 INNER JOIN youtube_channel c ON v.channel_id = c.id
+				 LEFT JOIN blocked_channels bc ON c.id = bc.channel_id
+				 LEFT JOIN blocked_videos bv ON v.id = bv.video_id
+		WHERE v.content_type = ?
+		  AND COALESCE(v.scheduled_start_at, v.published_at) > ?
+		  AND v.is_available = TRUE
+		  AND bc.channel_id IS NULL
+		  AND bv.video_id IS NULL
+		  %s
+		ORDER BY COALESCE(v.scheduled_start_at, v.published_at) %s
+		LIMIT ?
+	`, conditions, order)
+
+	args := append([]interface{}{contentType, startsAfter}, conditionArgs...)
+	args = append(args, limit)
+
+	err := y.db.SelectContext(ctx, &videos, query, args...)
 	if err != nil {
-		log.Printf("[ERROR] Error getting top ranked channel videos: %s", err)
+		log.Printf("[ERROR] Error getting %s videos: %s", contentType, err)
 		return videos, err
 	}
 
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+const YouTubeChannelSyncSchema = `
+	CREATE TABLE IF NOT EXISTS youtube_channel_sync (
+		channel_id TEXT PRIMARY KEY,
+		etag TEXT DEFAULT '',
+		last_checked_at TIMESTAMP,
+		backfilled_at TIMESTAMP
+	)
+`
+
+const YouTubeQuotaUsageSchema = `
+	CREATE TABLE IF NOT EXISTS youtube_quota_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		day TEXT NOT NULL,
+		units INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+type YouTubeChannelSync struct {
+	ChannelID     string     `json:"channelId" db:"channel_id"`
+	ETag          string     `json:"etag" db:"etag"`
+	LastCheckedAt time.Time  `json:"lastCheckedAt" db:"last_checked_at"`
+	BackfilledAt  *time.Time `json:"backfilledAt" db:"backfilled_at"`
+}
+
+type YouTubeQuotaUsage struct {
+	ID        int    `json:"id" db:"id"`
+	Day       string `json:"day" db:"day"`
+	Units     int64  `json:"units" db:"units"`
+	CreatedAt string `json:"createdAt" db:"created_at"`
+}
+
+type YouTubeSyncRepository struct {
+	db *sqlx.DB
+}
+
+func NewYouTubeSyncRepository(db *sqlx.DB) (*YouTubeSyncRepository, error) {
+	_, err := db.Exec(YouTubeChannelSyncSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating youtube_channel_sync table: %s", err)
+		return nil, err
+	}
+
+	_, err = db.Exec(YouTubeQuotaUsageSchema)
+	if err != nil {
+		log.Printf("[ERROR] Error creating youtube_quota_usage table: %s", err)
+		return nil, err
+	}
+
+	return &YouTubeSyncRepository{db: db}, nil
+}
+
+func (y *YouTubeSyncRepository) GetChannelSync(ctx context.Context, channelID string) (*YouTubeChannelSync, error) {
+	var channelSync YouTubeChannelSync
+	err := y.db.GetContext(ctx, &channelSync, "SELECT * FROM youtube_channel_sync WHERE channel_id = ?", channelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		log.Printf("[ERROR] Error getting channel sync: %s", err)
+		return nil, err
+	}
+
+	return &channelSync, nil
+}
+
+func (y *YouTubeSyncRepository) SaveChannelSync(ctx context.Context, channelSync YouTubeChannelSync) error {
+	query := `
+		INSERT INTO youtube_channel_sync (channel_id, etag, last_checked_at, backfilled_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET
+			etag = excluded.etag,
+			last_checked_at = excluded.last_checked_at,
+			backfilled_at = excluded.backfilled_at
+	`
+	_, err := y.db.ExecContext(ctx, query, channelSync.ChannelID, channelSync.ETag, channelSync.LastCheckedAt, channelSync.BackfilledAt)
+	if err != nil {
+		log.Printf("[ERROR] Error saving channel sync: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (y *YouTubeSyncRepository) CreateQuotaUsage(ctx context.Context, usage YouTubeQuotaUsage) error {
+	_, err := y.db.ExecContext(ctx, "INSERT INTO youtube_quota_usage (day, units) VALUES (?, ?)", usage.Day, usage.Units)
+	if err != nil {
+		log.Printf("[ERROR] Error inserting quota usage: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetQuotaUnits returns the quota units recorded for the given quota day.
+func (y *YouTubeSyncRepository) GetQuotaUnits(ctx context.Context, day string) (int64, error) {
+	var units int64
+	err := y.db.GetContext(ctx, &units, "SELECT COALESCE(SUM(units), 0) FROM youtube_quota_usage WHERE day = ?", day)
+	if err != nil {
+		log.Printf("[ERROR] Error getting quota units: %s", err)
+		return 0, err
+	}
+
+	return units, nil
+}
@@ -1,6 +1,9 @@
 package database
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
 
 const YouTubeWatchlistSchema = `
 	CREATE TABLE IF NOT EXISTS youtube_watchlist (
@@ -44,3 +47,13 @@ func (y *YouTubeWatchlistRepository) Create(youtubeWatchlist YouTubeWatchlist) (
 
 	return &youtubeWatchlist, nil
 }
+
+func (y *YouTubeWatchlistRepository) GetAllVideoIDs(ctx context.Context) ([]string, error) {
+	videoIDs := make([]string, 0)
+	err := y.db.SelectContext(ctx, &videoIDs, `SELECT video_id FROM youtube_watchlist`)
+	if err != nil {
+		return nil, err
+	}
+
+	return videoIDs, nil
+}
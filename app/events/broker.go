@@ -0,0 +1,82 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	TypeYouTubeVideosAdded   = "youtube.videos.added"
+	TypeYouTubeVideosUpdated = "youtube.videos.updated"
+	TypeStreamsStarted       = "twitch.streams.started"
+	TypeStreamsEnded         = "twitch.streams.ended"
+	TypeESportMatchesChanged = "esport.matches.changed"
+	TypeBlocklistChanged     = "user.blocklist.changed"
+	TypeWatchlistChanged     = "user.watchlist.changed"
+	TypeRankingChanged       = "youtube.ranking.changed"
+	TypeSubscriptionsChanged = "youtube.subscriptions.changed"
+)
+
+const subscriberBufferSize = 16
+
+type Event struct {
+	Type string    `json:"type"`
+	Data any       `json:"data"`
+	At   time.Time `json:"at"`
+}
+
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every published event and a function
+// that must be called to stop receiving them.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers the event to all subscribers without blocking. Subscribers
+// that fall behind miss the event instead of holding up the publisher.
+func (b *Broker) Publish(eventType string, data any) {
+	event := Event{
+		Type: eventType,
+		Data: data,
+		At:   time.Now(),
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("[WARN] dropping %s event for slow subscriber", eventType)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"content-oracle/app/events"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -34,6 +35,9 @@ func (c *Server) createActivityHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		c.FeedCache.Invalidate()
+		c.EventBroker.Publish(events.TypeBlocklistChanged, blockedVideo)
+
 		err = json.NewEncoder(w).Encode(CreateActivityResponse{
 			ID:      blockedVideo.ID,
 			VideoID: blockedVideo.VideoID,
@@ -55,6 +59,9 @@ func (c *Server) createActivityHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		c.FeedCache.Invalidate()
+		c.EventBroker.Publish(events.TypeBlocklistChanged, blockedChannel)
+
 		err = json.NewEncoder(w).Encode(CreateActivityResponse{
 			ID:        blockedChannel.ID,
 			ChannelID: blockedChannel.ChannelID,
@@ -1,10 +1,23 @@
 package http
 
 import (
+	"content-oracle/app/database"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// OAuthStateTTL is how long a started authorization flow can be finished.
+const OAuthStateTTL = 10 * time.Minute
+
 func (c *Server) twitchAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := c.consumeOAuthState(w, r, database.OAuthProviderTwitch); !ok {
+		return
+	}
+
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		http.Error(w, "code not found", http.StatusBadRequest)
@@ -20,16 +33,102 @@ func (c *Server) twitchAuthCallbackHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (c *Server) youtubeAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	state, ok := c.consumeOAuthState(w, r, database.OAuthProviderYouTube)
+	if !ok {
+		return
+	}
+
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		http.Error(w, "code not found", http.StatusBadRequest)
 		return
 	}
 
-	if err := c.YouTubeService.HandleAuthCode(code); err != nil {
+	if err := c.YouTubeService.HandleAuthCode(code, state.CodeVerifier); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
+
+// newOAuthState stores a random state for a new authorization flow and sets it
+// in a cookie, so that its callback can be told apart from one started by
+// someone else or in another browser.
+func (c *Server) newOAuthState(w http.ResponseWriter, r *http.Request, provider, codeVerifier string) (database.OAuthState, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return database.OAuthState{}, err
+	}
+
+	state := database.OAuthState{
+		State:        base64.RawURLEncoding.EncodeToString(b),
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(OAuthStateTTL),
+	}
+
+	if err := c.OAuthStateRepository.Create(r.Context(), state); err != nil {
+		return database.OAuthState{}, err
+	}
+
+	http.SetCookie(w, oauthStateCookie(provider, state.State, int(OAuthStateTTL.Seconds()), c.isSecure(r)))
+
+	return state, nil
+}
+
+// consumeOAuthState checks the state of a callback against the cookie of the
+// browser and the flows started for provider, and writes an error response
+// when it does not match, is unknown or expired.
+func (c *Server) consumeOAuthState(w http.ResponseWriter, r *http.Request, provider string) (*database.OAuthState, bool) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		http.Error(w, "state not found", http.StatusBadRequest)
+		return nil, false
+	}
+
+	cookie, err := r.Cookie(oauthStateCookieName(provider))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "state does not match this browser", http.StatusBadRequest)
+		return nil, false
+	}
+
+	http.SetCookie(w, oauthStateCookie(provider, "", -1, c.isSecure(r)))
+
+	oauthState, err := c.OAuthStateRepository.Consume(r.Context(), state, provider)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if oauthState == nil {
+		http.Error(w, "unknown or expired state", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return oauthState, true
+}
+
+func oauthStateCookieName(provider string) string {
+	return "oauth_state_" + provider
+}
+
+// isSecure reports whether the app is served over HTTPS. Browsers drop Secure
+// cookies on plain HTTP, which self-hosted setups often use on the LAN.
+func (c *Server) isSecure(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(c.BaseUrl, "https://")
+}
+
+// oauthStateCookie is only sent to the callback of provider. SameSite=Lax
+// still lets the redirect back from the provider carry it.
+func oauthStateCookie(provider, state string, maxAge int, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     oauthStateCookieName(provider),
+		Value:    state,
+		Path:     "/auth/" + provider + "/callback",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"content-oracle/app/events"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultFeedCacheTTL = 30 * time.Second
+
+// FeedCache keeps encoded feed responses for a short time. Writes to the data
+// feeds are built from invalidate it directly. Broker events may be dropped or
+// handled late, so they only drop every entry as a backup.
+type FeedCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	entries    map[string]feedCacheEntry
+	generation uint64
+	broker     *events.Broker
+}
+
+type FeedCacheOptions struct {
+	TTL    time.Duration
+	Broker *events.Broker
+}
+
+type feedCacheEntry struct {
+	body      []byte
+	etag      string
+	expiresAt time.Time
+}
+
+func NewFeedCache(opt FeedCacheOptions) *FeedCache {
+	return &FeedCache{
+		ttl:     opt.TTL,
+		entries: make(map[string]feedCacheEntry),
+		broker:  opt.Broker,
+	}
+}
+
+// Start invalidates the cache on every broker event until ctx is done, as a
+// backup for writes that do not invalidate it themselves.
+func (f *FeedCache) Start(ctx context.Context) {
+	if f.broker == nil {
+		return
+	}
+
+	events, unsubscribe := f.broker.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+
+			f.Invalidate()
+		}
+	}
+}
+
+func (f *FeedCache) Enabled() bool {
+	return f != nil && f.ttl > 0
+}
+
+// Get returns the cached entry for key and the current generation. The
+// generation must be passed back to Set so that a response built before an
+// invalidation is not stored afterwards.
+func (f *FeedCache) Get(key string) (feedCacheEntry, uint64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.entries[key]
+	if ok && time.Now().After(entry.expiresAt) {
+		delete(f.entries, key)
+		ok = false
+	}
+
+	return entry, f.generation, ok
+}
+
+func (f *FeedCache) Set(key string, generation uint64, entry feedCacheEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if generation != f.generation {
+		return
+	}
+
+	now := time.Now()
+	for k, e := range f.entries {
+		if now.After(e.expiresAt) {
+			delete(f.entries, k)
+		}
+	}
+
+	entry.expiresAt = now.Add(f.ttl)
+	f.entries[key] = entry
+}
+
+func (f *FeedCache) Invalidate() {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.generation++
+	clear(f.entries)
+}
+
+// feedCacheKey normalizes the query string so that parameter order does not
+// produce separate entries.
+func feedCacheKey(values url.Values) string {
+	return values.Encode()
+}
+
+// matchesETag reports whether an If-None-Match header value lists etag.
+// Weak validators match their strong counterpart.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newFeedCacheEntry(resp any) (feedCacheEntry, error) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return feedCacheEntry{}, err
+	}
+	body = append(body, '\n')
+
+	sum := sha256.Sum256(body)
+
+	return feedCacheEntry{
+		body: body,
+		etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
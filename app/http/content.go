@@ -4,35 +4,243 @@ import (
 	"content-oracle/app/content"
 	"content-oracle/app/providers"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type GetAllContentResponse struct {
 	ContentList    []content.Content       `json:"contentList"`
 	EsportsMatches []providers.ESportMatch `json:"esportsMatches"`
+	Sources        []content.Source        `json:"sources"`
+	Cursors        map[string]string       `json:"cursors"`
 }
 
+const MaxContentPageLimit = 100
+
 func (c *Server) getAllContentHandler(w http.ResponseWriter, r *http.Request) {
-	contentList, err := c.ContentMultiProvider.GetAll()
+	query, err := parseContentQuery(r.URL.Query())
 	if err != nil {
-		log.Printf("[ERROR] failed to get all content: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var cacheKey string
+	var cacheGeneration uint64
+	if c.FeedCache.Enabled() {
+		cacheKey = feedCacheKey(r.URL.Query())
+
+		entry, generation, ok := c.FeedCache.Get(cacheKey)
+		if ok {
+			writeFeedCacheEntry(w, r, entry)
+			return
+		}
+		cacheGeneration = generation
 	}
 
-	eSportMatches, err := c.ESportMultiProvider.GetAll()
+	var resp GetAllContentResponse
+	if category := r.URL.Query().Get("category"); category != "" {
+		resp, err = c.getContentPage(r, category, query)
+	} else {
+		resp = c.getAllContent(r, query)
+	}
 	if err != nil {
-		log.Printf("[ERROR] failed to get all esports matches: %s", err)
+		if errors.Is(err, content.ErrUnknownCategory) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	err = json.NewEncoder(w).Encode(GetAllContentResponse{
-		ContentList:    contentList,
-		EsportsMatches: eSportMatches,
-	})
+	entry, err := newFeedCacheEntry(resp)
 	if err != nil {
 		log.Printf("[ERROR] failed to encode content response: %s", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	// Partial results are not cached, the next request retries failed sources.
+	if c.FeedCache.Enabled() && allSourcesOK(resp.Sources) {
+		c.FeedCache.Set(cacheKey, cacheGeneration, entry)
+	}
+
+	writeFeedCacheEntry(w, r, entry)
+}
+
+func (c *Server) getAllContent(r *http.Request, query content.Query) GetAllContentResponse {
+	contentResult := c.ContentMultiProvider.GetAll(r.Context(), query)
+	eSportMatches, eSportSources := c.ESportMultiProvider.GetAll(r.Context())
+
+	contentList, err := c.ContentRanking.Rank(r.Context(), contentResult.Content)
+	if err != nil {
+		log.Printf("[ERROR] failed to rank content: %s", err)
+	}
+
+	return GetAllContentResponse{
+		ContentList:    contentList,
+		EsportsMatches: eSportMatches,
+		Sources:        append(contentResult.Sources, eSportSources...),
+		Cursors:        contentResult.Cursors,
+	}
+}
+
+func (c *Server) getContentPage(r *http.Request, category string, query content.Query) (GetAllContentResponse, error) {
+	contentResult, err := c.ContentMultiProvider.GetPage(r.Context(), category, query)
+	if err != nil {
+		return GetAllContentResponse{}, err
+	}
+
+	contentList, err := c.ContentRanking.Score(r.Context(), contentResult.Content)
+	if err != nil {
+		log.Printf("[ERROR] failed to score content: %s", err)
+	}
+
+	return GetAllContentResponse{
+		ContentList:    contentList,
+		EsportsMatches: make([]providers.ESportMatch, 0),
+		Sources:        contentResult.Sources,
+		Cursors:        contentResult.Cursors,
+	}, nil
+}
+
+func writeFeedCacheEntry(w http.ResponseWriter, r *http.Request, entry feedCacheEntry) {
+	w.Header().Set("ETag", entry.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if matchesETag(r.Header.Get("If-None-Match"), entry.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(entry.body); err != nil {
+		log.Printf("[ERROR] failed to write content response: %s", err)
+	}
+}
+
+func allSourcesOK(sources []content.Source) bool {
+	for _, source := range sources {
+		if source.Status != content.SourceStatusOK {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseContentQuery(values url.Values) (content.Query, error) {
+	var query content.Query
+	var err error
+
+	if query.MinDuration, err = parseDurationParam(values, "minDuration"); err != nil {
+		return query, err
+	}
+
+	if query.MaxDuration, err = parseDurationParam(values, "maxDuration"); err != nil {
+		return query, err
+	}
+
+	if query.FitsIn, err = parseDurationParam(values, "fitsIn"); err != nil {
+		return query, err
+	}
+
+	if query.Since, err = parseSinceParam(values, "since"); err != nil {
+		return query, err
+	}
+
+	// A cursor belongs to the list of a single category, other categories
+	// would skip or repeat items with it.
+	if values.Get("cursor") != "" && values.Get("category") == "" {
+		return query, errors.New("cursor requires a category")
+	}
+
+	if query.Cursor, err = content.DecodeCursor(values.Get("cursor")); err != nil {
+		return query, err
+	}
+
+	if query.Limit, err = parseLimitParam(values, "limit"); err != nil {
+		return query, err
+	}
+
+	if query.PerChannelLimit, err = parseLimitParam(values, "perChannel"); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+func parseLimitParam(values url.Values, name string) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > MaxContentPageLimit {
+		return 0, fmt.Errorf("invalid %s: must be between 1 and %d", name, MaxContentPageLimit)
+	}
+
+	return limit, nil
+}
+
+// parseSinceParam accepts a number of days ("30d"), a Go duration ("72h"),
+// a date ("2024-10-01") or an RFC 3339 timestamp.
+func parseSinceParam(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return time.Now().Add(-duration), nil
+	}
+
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return date, nil
+	}
+
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid %s: %q", name, value)
+}
+
+// parseDurationParam accepts either a plain number of minutes or a Go duration
+// string such as "1h30m".
+func parseDurationParam(values url.Values, name string) (time.Duration, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	if minutes, err := strconv.Atoi(value); err == nil {
+		if minutes < 0 {
+			return 0, fmt.Errorf("invalid %s: must not be negative", name)
+		}
+
+		return time.Duration(minutes) * time.Minute, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+
+	return duration, nil
 }
 
 type OpenContentRequest struct {
@@ -47,7 +255,7 @@ func (c *Server) openContentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = c.ZimaClient.OpenUrl(req.Url); err != nil {
+	if err = c.ZimaClient.OpenUrl(r.Context(), req.Url); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -7,7 +7,7 @@ import (
 )
 
 func (c *Server) getHistoryHandler(w http.ResponseWriter, r *http.Request) {
-	historyList, err := c.UserHistory.GetAll()
+	historyList, err := c.UserHistory.GetAll(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -2,8 +2,11 @@ package http
 
 import (
 	"content-oracle/app/content"
+	"content-oracle/app/content/ranking"
 	"content-oracle/app/database"
+	"content-oracle/app/events"
 	"content-oracle/app/providers"
+	appsync "content-oracle/app/sync"
 	"content-oracle/app/user"
 	"context"
 	"encoding/json"
@@ -26,9 +29,19 @@ type Server struct {
 	UserHistory          *user.History
 	UserWatchlist        *user.Watchlist
 	BaseStaticPath       string
+	BaseUrl              string
 	Port                 int
 	ContentMultiProvider content.MultiProvider
 	ESportMultiProvider  content.MultiESportProvider
+	ContentRanking       *ranking.Engine
+	EventBroker          *events.Broker
+	FeedCache            *FeedCache
+	SyncRunRepository    *database.SyncRunRepository
+	OAuthStateRepository *database.OAuthStateRepository
+	YouTubeSync          *appsync.YouTubeProvider
+	// shutdown is closed when the server shuts down, so that long-lived
+	// streams end instead of holding up the shutdown.
+	shutdown chan struct{}
 }
 
 type ClientOptions struct {
@@ -41,7 +54,14 @@ type ClientOptions struct {
 	UserWatchlist        *user.Watchlist
 	ContentMultiProvider content.MultiProvider
 	ESportMultiProvider  content.MultiESportProvider
+	ContentRanking       *ranking.Engine
+	EventBroker          *events.Broker
+	FeedCache            *FeedCache
+	SyncRunRepository    *database.SyncRunRepository
+	OAuthStateRepository *database.OAuthStateRepository
+	YouTubeSync          *appsync.YouTubeProvider
 	BaseStaticPath       string
+	BaseUrl              string
 	Port                 int
 }
 
@@ -56,8 +76,16 @@ func NewServer(opt *ClientOptions) *Server {
 		UserHistory:          opt.UserHistory,
 		ContentMultiProvider: opt.ContentMultiProvider,
 		ESportMultiProvider:  opt.ESportMultiProvider,
+		ContentRanking:       opt.ContentRanking,
+		EventBroker:          opt.EventBroker,
+		FeedCache:            opt.FeedCache,
+		SyncRunRepository:    opt.SyncRunRepository,
+		OAuthStateRepository: opt.OAuthStateRepository,
+		YouTubeSync:          opt.YouTubeSync,
 		BaseStaticPath:       opt.BaseStaticPath,
+		BaseUrl:              opt.BaseUrl,
 		Port:                 opt.Port,
+		shutdown:             make(chan struct{}),
 	}
 }
 
@@ -73,6 +101,11 @@ func (c *Server) Start(ctx context.Context, done chan struct{}) {
 		}).Handler(mux),
 	}
 
+	// Shutdown does not cancel the contexts of active requests.
+	server.RegisterOnShutdown(func() {
+		close(c.shutdown)
+	})
+
 	go func() {
 		log.Printf("[INFO] Starting HTTP server on %s", server.Addr)
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
@@ -83,7 +116,8 @@ func (c *Server) Start(ctx context.Context, done chan struct{}) {
 
 	<-ctx.Done()
 
-	shutdownCtx, shutdownRelease := context.WithTimeout(ctx, 10*time.Second)
+	// ctx is already done here, in-flight requests get their own deadline.
+	shutdownCtx, shutdownRelease := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
 	defer shutdownRelease()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
@@ -99,6 +133,7 @@ func (c *Server) routes(router *http.ServeMux) *http.ServeMux {
 	router.HandleFunc("GET /auth/youtube/callback", c.youtubeAuthCallbackHandler)
 
 	router.HandleFunc("GET /api/content", c.getAllContentHandler)
+	router.HandleFunc("GET /api/content/stream", c.contentStreamHandler)
 	router.HandleFunc("POST /api/content/open", c.openContentHandler)
 
 	router.HandleFunc("POST /api/activity", c.createActivityHandler)
@@ -114,6 +149,11 @@ func (c *Server) routes(router *http.ServeMux) *http.ServeMux {
 
 	router.HandleFunc("POST /api/watchlist/youtube", c.addWatchlistItemHandler)
 
+	router.HandleFunc("POST /api/sync", c.startSyncHandler)
+	router.HandleFunc("POST /api/sync/channels/{id}", c.startChannelSyncHandler)
+	router.HandleFunc("GET /api/sync/runs", c.getSyncRunsHandler)
+	router.HandleFunc("GET /api/sync/runs/{id}", c.getSyncRunHandler)
+
 	router.HandleFunc("GET /api/health", c.healthHandler)
 	router.HandleFunc("GET /api/proxy", c.proxyHandler)
 	router.HandleFunc("GET /", c.fileHandler)
@@ -124,14 +164,25 @@ func (c *Server) routes(router *http.ServeMux) *http.ServeMux {
 type HealthResponse struct {
 	Message string `json:"message"`
 	YouTube bool   `json:"youtube"`
+	// YouTubeAuth tells a missing authorization apart from a revoked one that
+	// needs the user to authorize the account again.
+	YouTubeAuth  string                `json:"youtubeAuth"`
+	YouTubeCache *providers.CacheStats `json:"youtubeCache,omitempty"`
+	Twitch       bool                  `json:"twitch"`
+	TwitchAuth   string                `json:"twitchAuth"`
 }
 
 func (c *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	isYoutubeHealthy, _ := c.YouTubeService.ValidateToken()
+	twitchAuth := c.TwitchClient.AuthStatus()
 
 	err := json.NewEncoder(w).Encode(HealthResponse{
-		Message: "OK",
-		YouTube: isYoutubeHealthy,
+		Message:      "OK",
+		YouTube:      isYoutubeHealthy,
+		YouTubeAuth:  c.YouTubeService.AuthStatus(),
+		YouTubeCache: c.YouTubeService.CacheStats(),
+		Twitch:       twitchAuth == providers.TwitchAuthStatusOK,
+		TwitchAuth:   twitchAuth,
 	})
 	if err != nil {
 		log.Printf("[ERROR] failed to encode health response: %s", err)
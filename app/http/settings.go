@@ -2,13 +2,16 @@ package http
 
 import (
 	"content-oracle/app/database"
+	"content-oracle/app/events"
+	appsync "content-oracle/app/sync"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"golang.org/x/oauth2"
 	"log"
 	"net/http"
 	"sort"
-	"strings"
 )
 
 type YoutubeSubscription struct {
@@ -19,9 +22,17 @@ type YoutubeSubscription struct {
 	PreviewURL string `json:"previewUrl"`
 }
 
+type TwitchAccount struct {
+	UserId string `json:"userId"`
+	Login  string `json:"login"`
+	URL    string `json:"url,omitempty"`
+}
+
 type SettingsResponse struct {
 	Subscriptions []YoutubeSubscription     `json:"subscriptions"`
 	Ranking       []database.YouTubeRanking `json:"ranking"`
+	// Twitch is the linked account, null until one is authorized.
+	Twitch *TwitchAccount `json:"twitch"`
 }
 
 func (c *Server) getSettingsHandler(w http.ResponseWriter, r *http.Request) {
@@ -62,6 +73,18 @@ func (c *Server) getSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		Ranking:       ranking,
 	}
 
+	if userId, login := c.TwitchClient.Account(); userId != "" {
+		resp.Twitch = &TwitchAccount{
+			UserId: userId,
+			Login:  login,
+		}
+
+		// Only TWITCH_USER_ID is known until the account is authorized again.
+		if login != "" {
+			resp.Twitch.URL = fmt.Sprintf("https://www.twitch.tv/%s", login)
+		}
+	}
+
 	if err = json.NewEncoder(w).Encode(resp); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -76,8 +99,24 @@ func (c *Server) saveSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	previousRanking, err := c.YouTubeRepository.GetAllRanking()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wasRanked := make(map[string]bool)
+	for _, rank := range previousRanking {
+		wasRanked[rank.ID] = rank.Rank > 0
+	}
+
 	var rankings []database.YouTubeRanking
+	newlyRanked := make([]string, 0)
 	for _, rank := range req.Ranking {
+		if rank.Rank > 0 && !wasRanked[rank.ID] {
+			newlyRanked = append(newlyRanked, rank.ID)
+		}
+
 		rankings = append(rankings, database.YouTubeRanking{
 			ID:   rank.ID,
 			Rank: rank.Rank,
@@ -89,6 +128,13 @@ func (c *Server) saveSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	c.FeedCache.Invalidate()
+	c.EventBroker.Publish(events.TypeRankingChanged, rankings)
+
+	if len(newlyRanked) > 0 {
+		c.backfillChannels(r.Context(), newlyRanked)
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -102,7 +148,13 @@ func (c *Server) cleanSettingsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Server) authYoutubeClientHandler(w http.ResponseWriter, r *http.Request) {
-	url, err := c.YouTubeService.GetAuthURL()
+	state, err := c.newOAuthState(w, r, database.OAuthProviderYouTube, oauth2.GenerateVerifier())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	url, err := c.YouTubeService.GetAuthURL(state.State, state.CodeVerifier)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -112,46 +164,58 @@ func (c *Server) authYoutubeClientHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (c *Server) authTwitchClientHandler(w http.ResponseWriter, r *http.Request) {
-	url := c.TwitchClient.GetAuthURL()
+	state, err := c.newOAuthState(w, r, database.OAuthProviderTwitch, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	url := c.TwitchClient.GetAuthURL(state.State)
 
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
+// initChannelsHandler reconciles the stored channels with the YouTube
+// subscriptions right away instead of waiting for the next sync and responds
+// with what changed.
 func (c *Server) initChannelsHandler(w http.ResponseWriter, r *http.Request) {
-	service, err := c.YouTubeService.GetService(context.Background())
+	service, err := c.YouTubeService.GetService(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	userSubscriptions, err := c.YouTubeService.GetUserSubscriptions(service)
+	diff, err := c.YouTubeSync.ReconcileSubscriptions(r.Context(), service)
 	if err != nil {
+		if errors.Is(err, appsync.ErrSyncRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	for _, sub := range userSubscriptions {
-		channelID := sub.Snippet.ResourceId.ChannelId
-		channel, err := c.YouTubeRepository.GetChannelByID(channelID)
-		if err != nil {
-			log.Printf("[ERROR] failed to get channel by title: %s", err)
-			continue
-		}
+	c.FeedCache.Invalidate()
 
-		if channel != nil {
-			continue
-		}
+	if err = json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("[ERROR] failed to encode subscriptions diff: %s", err)
+	}
+}
 
-		title := strings.TrimSpace(sub.Snippet.Title)
-		channel, err = c.YouTubeRepository.CreateChannel(&database.YouTubeChannel{
-			ID:           channelID,
-			Title:        title,
-			PreviewURL:   sub.Snippet.Thumbnails.Medium.Url,
-			IsSubscribed: true,
-		})
-		if err != nil {
-			log.Printf("[ERROR] Error creating channel: %s %s", sub.Snippet.Title, err)
-			continue
+// backfillChannels loads the recent uploads of newly ranked channels right
+// away. When a sync is already running, the next one backfills them.
+func (c *Server) backfillChannels(ctx context.Context, channels []string) {
+	run, err := c.YouTubeSync.Backfill(context.WithoutCancel(ctx), channels)
+	if err != nil {
+		if errors.Is(err, appsync.ErrSyncRunning) {
+			log.Printf("[WARN] %d newly ranked channels will be backfilled by the next sync", len(channels))
+			return
 		}
+
+		log.Printf("[ERROR] failed to start backfill: %s", err)
+		return
 	}
+
+	log.Printf("[INFO] Started backfill run %d for %d newly ranked channels", run.ID, len(channels))
 }
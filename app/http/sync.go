@@ -0,0 +1,120 @@
+package http
+
+import (
+	"content-oracle/app/database"
+	appsync "content-oracle/app/sync"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const DefaultSyncRunsLimit = 20
+
+func (c *Server) startSyncHandler(w http.ResponseWriter, r *http.Request) {
+	c.startSync(w, r, appsync.SyncOptions{
+		TriggeredBy: database.SyncRunTriggerManual,
+	})
+}
+
+// startChannelSyncHandler resyncs one channel. The optional since parameter
+// accepts the same values as the content feed and defaults to the last
+// stored upload of the channel.
+func (c *Server) startChannelSyncHandler(w http.ResponseWriter, r *http.Request) {
+	channelID := r.PathValue("id")
+
+	since, err := parseSinceParam(r.URL.Query(), "since")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	channel, err := c.YouTubeRepository.GetChannelByID(channelID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if channel == nil {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+
+	c.startSync(w, r, appsync.SyncOptions{
+		TriggeredBy: database.SyncRunTriggerManual,
+		ChannelID:   channel.ID,
+		Since:       since,
+	})
+}
+
+func (c *Server) startSync(w http.ResponseWriter, r *http.Request, opt appsync.SyncOptions) {
+	// The run outlives the request, so it must not be canceled with it.
+	run, err := c.YouTubeSync.Start(context.WithoutCancel(r.Context()), opt)
+	if err != nil {
+		if errors.Is(err, appsync.ErrSyncRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/api/sync/runs/"+strconv.Itoa(run.ID))
+	w.WriteHeader(http.StatusAccepted)
+
+	if err = json.NewEncoder(w).Encode(run); err != nil {
+		log.Printf("[ERROR] failed to encode sync run response: %s", err)
+	}
+}
+
+func (c *Server) getSyncRunsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimitParam(r.URL.Query(), "limit")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if limit == 0 {
+		limit = DefaultSyncRunsLimit
+	}
+
+	runs, err := c.SyncRunRepository.GetAll(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err = json.NewEncoder(w).Encode(runs); err != nil {
+		log.Printf("[ERROR] failed to encode sync runs response: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (c *Server) getSyncRunHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid sync run id", http.StatusBadRequest)
+		return
+	}
+
+	run, err := c.SyncRunRepository.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if run == nil {
+		http.Error(w, "sync run not found", http.StatusNotFound)
+		return
+	}
+
+	if err = json.NewEncoder(w).Encode(run); err != nil {
+		log.Printf("[ERROR] failed to encode sync run response: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
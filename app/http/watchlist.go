@@ -1,6 +1,7 @@
 package http
 
 import (
+	"content-oracle/app/events"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -41,6 +42,9 @@ func (c *Server) addWatchlistItemHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	c.FeedCache.Invalidate()
+	c.EventBroker.Publish(events.TypeWatchlistChanged, watchlist)
+
 	resp := AddWatchlistItemResponse{
 		ID:      watchlist.ID,
 		VideoID: watchlist.VideoID,
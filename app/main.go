@@ -3,7 +3,9 @@ package main
 import (
 	"content-oracle/app/config"
 	"content-oracle/app/content"
+	"content-oracle/app/content/ranking"
 	"content-oracle/app/database"
+	"content-oracle/app/events"
 	"content-oracle/app/http"
 	"content-oracle/app/providers"
 	"content-oracle/app/scheduler"
@@ -72,6 +74,30 @@ func run(cfg *config.Config) error {
 		return err
 	}
 
+	youtubeSyncRepository, err := database.NewYouTubeSyncRepository(db)
+	if err != nil {
+		log.Printf("[ERROR] Error creating YouTube sync repository: %s", err)
+		return err
+	}
+
+	syncRunRepository, err := database.NewSyncRunRepository(db)
+	if err != nil {
+		log.Printf("[ERROR] Error creating sync run repository: %s", err)
+		return err
+	}
+
+	oauthStateRepository, err := database.NewOAuthStateRepository(db)
+	if err != nil {
+		log.Printf("[ERROR] Error creating OAuth state repository: %s", err)
+		return err
+	}
+
+	apiCacheRepository, err := database.NewAPICacheRepository(db)
+	if err != nil {
+		log.Printf("[ERROR] Error creating API cache repository: %s", err)
+		return err
+	}
+
 	twitchClient, err := providers.NewTwitch(&providers.TwitchOptions{
 		SettingsRepository: settingsRepository,
 		RedirectURI:        cfg.Twitch.RedirectURI,
@@ -84,6 +110,14 @@ func run(cfg *config.Config) error {
 		return err
 	}
 
+	youtubeCache := providers.NewSQLiteCache(providers.SQLiteCacheOptions{
+		Repository:    apiCacheRepository,
+		TTLs:          cfg.Youtube.CacheTTLs,
+		MaxEntries:    cfg.Youtube.CacheMaxEntries,
+		PruneInterval: cfg.Youtube.CachePruneInterval,
+	})
+	go youtubeCache.Start(ctx)
+
 	youtubeClient, err := providers.NewYoutube(&providers.YoutubeOptions{
 		ClientID:           cfg.Youtube.ClientID,
 		ClientSecret:       cfg.Youtube.ClientSecret,
@@ -91,6 +125,9 @@ func run(cfg *config.Config) error {
 		ConfigPath:         cfg.Youtube.ConfigPath,
 		SettingsRepository: settingsRepository,
 		YouTubeRepository:  youTubeRepository,
+		RequestsPerSecond:  cfg.Youtube.RequestsPerSecond,
+		RequestBurst:       cfg.Youtube.RequestBurst,
+		Cache:              youtubeCache,
 	})
 	if err != nil {
 		log.Printf("[ERROR] Error creating YouTube client: %s", err)
@@ -98,44 +135,93 @@ func run(cfg *config.Config) error {
 	}
 
 	zimaClient := providers.NewZima(cfg.Zima.Url)
+	eventBroker := events.NewBroker()
 
-	syncYoutubeProvider := sync.NewYouTubeProvider(sync.YouTubeProviderOptions{
-		YoutubeRepository: youTubeRepository,
-		YoutubeClient:     youtubeClient,
-		ZimaClient:        zimaClient,
+	youtubeFeed := sync.NewYouTubeFeed(sync.YouTubeFeedOptions{
+		BaseURL: cfg.Youtube.FeedBaseURL,
 	})
 
-	twitchContentProvider := content.NewTwitch(content.TwitchOptions{
-		TwitchClient: twitchClient,
+	feedCache := http.NewFeedCache(http.FeedCacheOptions{
+		TTL:    cfg.Content.CacheTTL,
+		Broker: eventBroker,
 	})
+	go feedCache.Start(ctx)
 
-	youtubeSubscriptionContentProvider := content.NewYouTubeSubscription(content.YouTubeSubscriptionOptions{
-		YoutubeRepository: youTubeRepository,
-	})
-
-	youtubeUnsubscribeChannelsContentProvider := content.NewYouTubeUnsubscribeChannels(content.YouTubeUnsubscribeChannelsOptions{
-		YoutubeRepository: youTubeRepository,
+	syncYoutubeProvider := sync.NewYouTubeProvider(sync.YouTubeProviderOptions{
+		YoutubeRepository:     youTubeRepository,
+		YouTubeSyncRepository: youtubeSyncRepository,
+		SyncRunRepository:     syncRunRepository,
+		YoutubeClient:         youtubeClient,
+		YouTubeFeed:           youtubeFeed,
+		ZimaClient:            zimaClient,
+		EventBroker:           eventBroker,
+		FeedCache:             feedCache,
+		DailyQuota:            cfg.Youtube.DailyQuota,
+		QuotaSlowdownRatio:    cfg.Youtube.QuotaSlowdownRatio,
+		SlowSyncInterval:      cfg.Youtube.SlowSyncInterval,
+		Concurrency:           cfg.Youtube.SyncConcurrency,
+		Retries:               cfg.Youtube.SyncRetries,
+		RetryDelay:            cfg.Youtube.SyncRetryDelay,
+		BackfillVideos:        cfg.Youtube.BackfillVideos,
+		RefreshLookback:       cfg.Youtube.RefreshLookback,
+		RefreshLimit:          cfg.Youtube.RefreshLimit,
 	})
 
-	youtubeWatchlistContentProvider := content.NewYouTubeWatchlist(youTubeRepository)
-
-	contentMultiProvider := content.NewMultiProvider(
-		zimaClient,
-		blockedVideoRepository,
-		twitchContentProvider,
-		youtubeWatchlistContentProvider,
-		youtubeSubscriptionContentProvider,
-		youtubeUnsubscribeChannelsContentProvider,
-	)
-
 	esportClient := providers.NewEsport(&providers.ESportOptions{
 		ApiKey:  cfg.Esport.ApiKey,
 		BaseURL: cfg.Esport.BaseUrl,
 		TeamIds: cfg.Esport.Teams,
 	})
 
-	esportEventsProvider := content.NewESportEvents(esportClient)
-	esportMultiProvider := content.MultiESportProvider{esportEventsProvider}
+	contentSources, err := content.NewSources(content.Dependencies{
+		TwitchClient:           twitchClient,
+		ZimaClient:             zimaClient,
+		ESportClient:           esportClient,
+		YouTubeRepository:      youTubeRepository,
+		BlockedVideoRepository: blockedVideoRepository,
+	}, content.SourcesOptions{
+		Enabled:    cfg.Content.Sources,
+		Categories: cfg.Content.Categories,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Error creating content sources: %s", err)
+		return err
+	}
+
+	providerTimeouts := content.Timeouts{
+		Default:   cfg.Content.ProviderTimeout,
+		Providers: cfg.Content.ProviderTimeouts,
+	}
+
+	contentMultiProvider := content.NewMultiProvider(content.MultiProviderOptions{
+		History:   contentSources.History,
+		Providers: contentSources.Providers,
+		Timeouts:  providerTimeouts,
+		Lookbacks: content.Lookbacks{
+			Default:   cfg.Content.Lookback,
+			Providers: cfg.Content.Lookbacks,
+		},
+	})
+
+	esportMultiProvider := content.NewMultiESportProvider(content.MultiESportProviderOptions{
+		Providers: contentSources.ESportProviders,
+		Timeouts:  providerTimeouts,
+	})
+
+	contentWatcher := content.NewWatcher(content.WatcherOptions{
+		StreamProvider: contentSources.Provider(content.TwitchProviderName),
+		ESportProvider: contentSources.ESportProvider(content.ESportEventsProviderName),
+		Broker:         eventBroker,
+		Interval:       cfg.Content.WatchInterval,
+		Timeouts:       providerTimeouts,
+	})
+	go contentWatcher.Start(ctx)
+
+	contentRanking := ranking.NewEngine(ranking.EngineOptions{
+		YouTubeRepository:          youTubeRepository,
+		YouTubeWatchlistRepository: youtubeWatchlistRepository,
+		Weights:                    ranking.DefaultWeights,
+	})
 
 	userActivity := user.NewActivity(blockedVideoRepository, blockedChannelRepository)
 	userHistory := user.NewHistory(zimaClient, cfg.Http.BaseUrl)
@@ -151,6 +237,11 @@ func run(cfg *config.Config) error {
 		log.Printf("[ERROR] Error starting scheduler client: %s", err)
 	}
 
+	err = schedulerClient.Every(cfg.Youtube.RefreshInterval, syncYoutubeProvider.Refresh, context.Background())
+	if err != nil {
+		log.Printf("[ERROR] Error scheduling YouTube refresh: %s", err)
+	}
+
 	_, nextRun := schedulerClient.NextRun()
 	log.Printf("[INFO] Scheduler client started. Next run at %s", nextRun.Local())
 
@@ -164,7 +255,14 @@ func run(cfg *config.Config) error {
 		UserWatchlist:        userWatchlist,
 		ContentMultiProvider: contentMultiProvider,
 		ESportMultiProvider:  esportMultiProvider,
+		ContentRanking:       contentRanking,
+		EventBroker:          eventBroker,
+		FeedCache:            feedCache,
+		SyncRunRepository:    syncRunRepository,
+		OAuthStateRepository: oauthStateRepository,
+		YouTubeSync:          syncYoutubeProvider,
 		BaseStaticPath:       cfg.Http.BaseStaticPath,
+		BaseUrl:              cfg.Http.BaseUrl,
 		Port:                 cfg.Http.Port,
 	}).Start(ctx, done)
 
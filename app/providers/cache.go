@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"content-oracle/app/database"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Key families of cached API responses. Each family has its own TTL.
+const (
+	CacheFamilyChannel      = "channel"
+	CacheFamilyVideo        = "video"
+	CacheFamilySearch       = "search"
+	CacheFamilySubscription = "subscription"
+)
+
+// DefaultCacheTTLs keeps what rarely changes, like the channel of a video,
+// for long and subscription state only briefly.
+var DefaultCacheTTLs = map[string]time.Duration{
+	CacheFamilyChannel:      7 * 24 * time.Hour,
+	CacheFamilyVideo:        24 * time.Hour,
+	CacheFamilySearch:       7 * 24 * time.Hour,
+	CacheFamilySubscription: 15 * time.Minute,
+}
+
+const (
+	DefaultCacheMaxEntries    = 10000
+	DefaultCachePruneInterval = 10 * time.Minute
+)
+
+// Cache stores API responses by family and key. Get decodes a hit into value,
+// which must be a pointer.
+type Cache interface {
+	Get(ctx context.Context, family, key string, value any) (bool, error)
+	Set(ctx context.Context, family, key string, value any) error
+	Stats() CacheStats
+}
+
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// SQLiteCache keeps API responses as JSON in SQLite, so they survive restarts.
+type SQLiteCache struct {
+	repository    *database.APICacheRepository
+	ttls          map[string]time.Duration
+	maxEntries    int
+	pruneInterval time.Duration
+	hits          atomic.Int64
+	misses        atomic.Int64
+}
+
+type SQLiteCacheOptions struct {
+	Repository *database.APICacheRepository
+	// TTLs overrides the default TTL of the given families.
+	TTLs          map[string]time.Duration
+	MaxEntries    int
+	PruneInterval time.Duration
+}
+
+func NewSQLiteCache(opt SQLiteCacheOptions) *SQLiteCache {
+	ttls := make(map[string]time.Duration, len(DefaultCacheTTLs))
+	for family, ttl := range DefaultCacheTTLs {
+		ttls[family] = ttl
+	}
+
+	for family, ttl := range opt.TTLs {
+		if ttl > 0 {
+			ttls[family] = ttl
+		}
+	}
+
+	maxEntries := opt.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+
+	pruneInterval := opt.PruneInterval
+	if pruneInterval <= 0 {
+		pruneInterval = DefaultCachePruneInterval
+	}
+
+	return &SQLiteCache{
+		repository:    opt.Repository,
+		ttls:          ttls,
+		maxEntries:    maxEntries,
+		pruneInterval: pruneInterval,
+	}
+}
+
+// Start evicts expired entries and entries over the size limit every prune
+// interval until ctx is done.
+func (c *SQLiteCache) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.repository.Prune(ctx, c.maxEntries)
+		}
+	}
+}
+
+func (c *SQLiteCache) Get(ctx context.Context, family, key string, value any) (bool, error) {
+	entry, err := c.repository.Get(ctx, cacheKey(family, key))
+	if err != nil || entry == nil {
+		c.misses.Add(1)
+		return false, err
+	}
+
+	if err := json.Unmarshal([]byte(entry.Value), value); err != nil {
+		c.misses.Add(1)
+		return false, err
+	}
+
+	c.hits.Add(1)
+
+	return true, nil
+}
+
+// Set stores value for the TTL of its family. Families without a TTL are not
+// cached.
+func (c *SQLiteCache) Set(ctx context.Context, family, key string, value any) error {
+	ttl, ok := c.ttls[family]
+	if !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return c.repository.Set(ctx, database.APICacheEntry{
+		Key:       cacheKey(family, key),
+		Family:    family,
+		Value:     string(data),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+func (c *SQLiteCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+func cacheKey(family, key string) string {
+	return family + ":" + key
+}
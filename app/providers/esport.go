@@ -2,6 +2,7 @@ package providers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -60,7 +61,7 @@ type getMatchesResponse struct {
 	Data []ESportMatch `json:"data"`
 }
 
-func (c *ESport) GetMatches() ([]ESportMatch, error) {
+func (c *ESport) GetMatches(ctx context.Context) ([]ESportMatch, error) {
 	after := time.Now().Add(-time.Hour * 24 * 15)
 	bodyBytes, err := json.Marshal(getMatchesRequest{Ids: c.TeamIds, After: after})
 	if err != nil {
@@ -68,7 +69,13 @@ func (c *ESport) GetMatches() ([]ESportMatch, error) {
 	}
 
 	reader := bytes.NewReader(bodyBytes)
-	resp, err := http.Post(c.BaseURL+"/events", "application/json", reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/events", reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -2,14 +2,41 @@ package providers
 
 import (
 	"content-oracle/app/database"
+	"errors"
+	"fmt"
 	"github.com/nicklaw5/helix/v2"
 	"log"
+	"net/http"
+	"sync"
 )
 
+// Authorization states of the Twitch account reported by AuthStatus.
+const (
+	TwitchAuthStatusOK             = "ok"
+	TwitchAuthStatusUnauthorized   = "unauthorized"
+	TwitchAuthStatusReauthRequired = "reauth_required"
+)
+
+var (
+	ErrTwitchUnauthorized   = errors.New("twitch account is not authorized")
+	ErrTwitchReauthRequired = errors.New("twitch authorization was revoked or expired, re-auth required")
+)
+
+// Twitch keeps the refresh token to itself rather than handing it to helix,
+// so that a 401 is refreshed here, the new pair is persisted and a rejected
+// refresh token is reported instead of turning into an empty response.
+// Requests hold mu for reading because helix reads the access token without
+// its own lock.
 type Twitch struct {
+	mu                 sync.RWMutex
+	lookupMu           sync.Mutex
 	settingsRepository *database.SettingsRepository
 	helix              *helix.Client
 	userId             string
+	userLogin          string
+	accessToken        string
+	refreshToken       string
+	reauthRequired     bool
 }
 
 type TwitchOptions struct {
@@ -47,29 +74,47 @@ func NewTwitch(opt *TwitchOptions) (*Twitch, error) {
 		}
 	}
 
+	twitch := &Twitch{
+		settingsRepository: opt.SettingsRepository,
+		userId:             opt.UserId,
+		helix:              client,
+	}
+
+	// TWITCH_USER_ID is only a fallback for tokens stored before the account
+	// was looked up on authorization.
+	if appSettings != nil && appSettings.TwitchUserID != "" {
+		twitch.userId = appSettings.TwitchUserID
+		twitch.userLogin = appSettings.TwitchUserLogin
+	}
+
 	if appSettings != nil && appSettings.TwitchAccessToken != "" {
+		twitch.accessToken = appSettings.TwitchAccessToken
+		twitch.refreshToken = appSettings.TwitchRefreshToken
 		client.SetUserAccessToken(appSettings.TwitchAccessToken)
-		client.SetRefreshToken(appSettings.TwitchRefreshToken)
 	} else {
-		url := client.GetAuthorizationURL(&helix.AuthorizationURLParams{
-			ResponseType: "code",
-			Scopes:       []string{"user:read:follows"},
-		})
-
-		log.Printf("No Twitch access token found in settings")
-		log.Printf("Authorization URL: %s", url)
+		log.Printf("[WARN] No Twitch access token found in settings, authorize it from the settings page")
 	}
 
-	return &Twitch{
-		settingsRepository: opt.SettingsRepository,
-		userId:             opt.UserId,
-		helix:              client,
-	}, nil
+	return twitch, nil
 }
 
 func (c *Twitch) GetLiveStreams() (*helix.StreamsResponse, error) {
-	resp, err := c.helix.GetFollowedStream(&helix.FollowedStreamsParams{
-		UserID: c.userId,
+	userID, err := c.currentUserID()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *helix.StreamsResponse
+	err = c.call("get followed streams", func() (*helix.ResponseCommon, error) {
+		var err error
+		resp, err = c.helix.GetFollowedStream(&helix.FollowedStreamsParams{
+			UserID: userID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &resp.ResponseCommon, nil
 	})
 	if err != nil {
 		return nil, err
@@ -78,12 +123,205 @@ func (c *Twitch) GetLiveStreams() (*helix.StreamsResponse, error) {
 	return resp, nil
 }
 
+// Account returns the ID and login of the linked account, empty when it is
+// not known yet.
+func (c *Twitch) Account() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.userId, c.userLogin
+}
+
+func (c *Twitch) currentUserID() (string, error) {
+	if userID, _ := c.Account(); userID != "" {
+		return userID, nil
+	}
+
+	c.lookupMu.Lock()
+	defer c.lookupMu.Unlock()
+
+	// A concurrent request may have looked it up meanwhile.
+	if userID, _ := c.Account(); userID != "" {
+		return userID, nil
+	}
+
+	return c.lookupUser()
+}
+
+// lookupUser asks Twitch which account the token belongs to and stores it.
+// It must be called with lookupMu held.
+func (c *Twitch) lookupUser() (string, error) {
+	var resp *helix.UsersResponse
+	err := c.call("get twitch user", func() (*helix.ResponseCommon, error) {
+		var err error
+		resp, err = c.helix.GetUsers(&helix.UsersParams{})
+		if err != nil {
+			return nil, err
+		}
+
+		return &resp.ResponseCommon, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Data.Users) == 0 {
+		return "", errors.New("twitch returned no user for the token")
+	}
+
+	user := resp.Data.Users[0]
+	err = c.settingsRepository.SetTwitchUser(database.Settings{
+		TwitchUserID:    user.ID,
+		TwitchUserLogin: user.Login,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Error saving Twitch user: %s", err)
+	}
+
+	c.mu.Lock()
+	c.userId = user.ID
+	c.userLogin = user.Login
+	c.mu.Unlock()
+
+	return user.ID, nil
+}
+
+// call runs request and repeats it once with a refreshed token when Twitch
+// rejects the current one.
+func (c *Twitch) call(name string, request func() (*helix.ResponseCommon, error)) error {
+	resp, accessToken, err := c.do(request)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		if err := c.refresh(accessToken); err != nil {
+			return err
+		}
+
+		resp, _, err = c.do(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.mu.Lock()
+		c.reauthRequired = true
+		c.mu.Unlock()
+
+		return ErrTwitchReauthRequired
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to %s: %d %s", name, resp.StatusCode, resp.ErrorMessage)
+	}
+
+	return nil
+}
+
+// do also returns the access token the request was made with.
+func (c *Twitch) do(request func() (*helix.ResponseCommon, error)) (*helix.ResponseCommon, string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.reauthRequired {
+		return nil, "", ErrTwitchReauthRequired
+	}
+
+	if c.accessToken == "" {
+		return nil, "", ErrTwitchUnauthorized
+	}
+
+	resp, err := request()
+
+	return resp, c.accessToken, err
+}
+
+// AuthStatus reports whether the account is authorized or needs to be
+// authorized again.
+func (c *Twitch) AuthStatus() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.reauthRequired {
+		return TwitchAuthStatusReauthRequired
+	}
+
+	if c.accessToken == "" {
+		return TwitchAuthStatusUnauthorized
+	}
+
+	return TwitchAuthStatusOK
+}
+
+// refresh replaces staleAccessToken, the token a request was rejected with,
+// unless a concurrent request has already replaced it.
+func (c *Twitch) refresh(staleAccessToken string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reauthRequired {
+		return ErrTwitchReauthRequired
+	}
+
+	if c.accessToken != staleAccessToken {
+		return nil
+	}
+
+	if c.refreshToken == "" {
+		c.reauthRequired = true
+		return ErrTwitchReauthRequired
+	}
+
+	resp, err := c.helix.RefreshUserAccessToken(c.refreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to refresh twitch token: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		log.Printf("[WARN] Twitch refresh token was rejected, re-auth required: %s", resp.ErrorMessage)
+		c.reauthRequired = true
+		return ErrTwitchReauthRequired
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to refresh twitch token: %d %s", resp.StatusCode, resp.ErrorMessage)
+	}
+
+	err = c.settingsRepository.SetTwitchSettings(database.Settings{
+		TwitchAccessToken:  resp.Data.AccessToken,
+		TwitchRefreshToken: resp.Data.RefreshToken,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Error saving refreshed Twitch token: %s", err)
+	}
+
+	c.setTokens(resp.Data.AccessToken, resp.Data.RefreshToken)
+
+	return nil
+}
+
+// setTokens must be called with mu held.
+func (c *Twitch) setTokens(accessToken, refreshToken string) {
+	c.accessToken = accessToken
+	c.refreshToken = refreshToken
+	c.reauthRequired = false
+	c.helix.SetUserAccessToken(accessToken)
+}
+
 func (c *Twitch) SetAuthToken(code string) error {
+	c.mu.RLock()
 	resp, err := c.helix.RequestUserAccessToken(code)
+	c.mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to request twitch token: %d %s", resp.StatusCode, resp.ErrorMessage)
+	}
+
 	err = c.settingsRepository.SetTwitchSettings(database.Settings{
 		TwitchAccessToken:  resp.Data.AccessToken,
 		TwitchRefreshToken: resp.Data.RefreshToken,
@@ -92,15 +330,30 @@ func (c *Twitch) SetAuthToken(code string) error {
 		return err
 	}
 
-	c.helix.SetUserAccessToken(resp.Data.AccessToken)
-	c.helix.SetRefreshToken(resp.Data.RefreshToken)
+	c.mu.Lock()
+	c.setTokens(resp.Data.AccessToken, resp.Data.RefreshToken)
+	c.userId = ""
+	c.userLogin = ""
+	c.mu.Unlock()
+
+	// The account can differ from the previous one, and a failed lookup is
+	// retried by the next request.
+	c.lookupMu.Lock()
+	defer c.lookupMu.Unlock()
+
+	if _, err := c.lookupUser(); err != nil {
+		log.Printf("[ERROR] Error looking up Twitch user: %s", err)
+	}
 
 	return nil
 }
 
-func (c *Twitch) GetAuthURL() string {
+// GetAuthURL starts an authorization flow identified by state. Twitch does not
+// support PKCE for confidential clients, so state is the only protection.
+func (c *Twitch) GetAuthURL(state string) string {
 	return c.helix.GetAuthorizationURL(&helix.AuthorizationURLParams{
 		ResponseType: "code",
 		Scopes:       []string{"user:read:follows"},
+		State:        state,
 	})
 }
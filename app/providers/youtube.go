@@ -5,21 +5,33 @@ import (
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 	"log"
 	"os"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Estimated YouTube Data API quota cost of each request the client makes.
+const (
+	QuotaCostList   = 1
+	QuotaCostSearch = 100
+)
+
+// MaxVideoIDsPerRequest is the most video IDs a single Videos.List call accepts.
+const MaxVideoIDsPerRequest = 50
+
 type Youtube struct {
 	settingsRepository *database.SettingsRepository
 	youTubeRepository  *database.YouTubeRepository
-	tokenSource        oauth2.TokenSource
+	tokens             *youtubeTokenStore
 	oauthConfig        *oauth2.Config
-	cache              sync.Map
+	cache              Cache
+	quotaUnits         atomic.Int64
+	limiter            *RateLimiter
 	options            *YoutubeOptions
 }
 
@@ -30,6 +42,10 @@ type YoutubeOptions struct {
 	ConfigPath         string
 	SettingsRepository *database.SettingsRepository
 	YouTubeRepository  *database.YouTubeRepository
+	RequestsPerSecond  float64
+	RequestBurst       int
+	// Cache keeps API responses between requests. Without it nothing is cached.
+	Cache Cache
 }
 
 type Service = youtube.Service
@@ -59,46 +75,31 @@ func NewYoutube(opt *YoutubeOptions) (*Youtube, error) {
 	}
 
 	if appSettings.YoutubeAccessToken == "" {
-		authURL := config.AuthCodeURL(
-			"state-token",
-			oauth2.AccessTypeOffline,
-			oauth2.SetAuthURLParam("prompt", "consent"),
-			oauth2.SetAuthURLParam("redirect_uri", opt.RedirectURI),
-		)
-		log.Printf("Youtube auth URL: %v", authURL)
-	}
-
-	token := &oauth2.Token{
-		RefreshToken: appSettings.YoutubeRefreshToken,
-		AccessToken:  appSettings.YoutubeAccessToken,
-		TokenType:    "Bearer",
-		Expiry:       time.Time{}.Add(1),
+		log.Printf("[WARN] YouTube is not authorized, authorize it from the settings page")
 	}
-	tokenSource := config.TokenSource(context.Background(), token)
 
 	return &Youtube{
 		settingsRepository: opt.SettingsRepository,
 		youTubeRepository:  opt.YouTubeRepository,
-		tokenSource:        tokenSource,
+		tokens:             newYoutubeTokenStore(config, opt.SettingsRepository, appSettings),
 		oauthConfig:        config,
+		limiter:            NewRateLimiter(opt.RequestsPerSecond, opt.RequestBurst),
+		cache:              opt.Cache,
 		options:            opt,
 	}, nil
 }
 
-func (c *Youtube) HandleAuthCode(code string) error {
+// HandleAuthCode exchanges the code of a finished authorization flow, proving
+// with the PKCE verifier that the flow was started by this server.
+func (c *Youtube) HandleAuthCode(code, codeVerifier string) error {
 	ctx := context.Background()
-	token, err := c.oauthConfig.Exchange(ctx, code)
+	token, err := c.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
 		log.Printf("[ERROR] Unable to retrieve token from web: %v", err)
 		return err
 	}
 
-	c.tokenSource = c.oauthConfig.TokenSource(ctx, token)
-	err = c.settingsRepository.SetYoutubeSettings(database.Settings{
-		YoutubeAccessToken:  token.AccessToken,
-		YoutubeRefreshToken: token.RefreshToken,
-	})
-	if err != nil {
+	if err = c.tokens.Set(token); err != nil {
 		log.Printf("[ERROR] Unable to update youtube settings: %v", err)
 		return err
 	}
@@ -107,35 +108,18 @@ func (c *Youtube) HandleAuthCode(code string) error {
 }
 
 func (c *Youtube) CleanAuth() error {
-	return c.settingsRepository.SetYoutubeSettings(database.Settings{
-		YoutubeAccessToken:  "",
-		YoutubeRefreshToken: "",
-	})
+	return c.tokens.Clear()
 }
 
+// GetService returns a client authorized with the stored token. The token is
+// refreshed by the client itself whenever it expires.
 func (c *Youtube) GetService(ctx context.Context) (*Service, error) {
-	token, err := c.tokenSource.Token()
-	if err != nil {
+	if _, err := c.tokens.Token(); err != nil {
 		log.Printf("[ERROR] Unable to retrieve token: %v", err)
 		return nil, err
 	}
 
-	newToken, err := oauth2.ReuseTokenSource(token, c.tokenSource).Token()
-	if err != nil {
-		log.Printf("[ERROR] Unable to refresh token: %v", err)
-		return nil, err
-	}
-
-	err = c.settingsRepository.SetYoutubeSettings(database.Settings{
-		YoutubeAccessToken:  newToken.AccessToken,
-		YoutubeRefreshToken: newToken.RefreshToken,
-	})
-	if err != nil {
-		log.Printf("[ERROR] Unable to update youtube settings: %v", err)
-		return nil, err
-	}
-
-	service, err := youtube.NewService(ctx, option.WithHTTPClient(c.oauthConfig.Client(ctx, newToken)))
+	service, err := youtube.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, c.tokens)))
 	if err != nil {
 		log.Printf("[ERROR] Unable to create youtube client: %v", err)
 		return nil, err
@@ -144,6 +128,12 @@ func (c *Youtube) GetService(ctx context.Context) (*Service, error) {
 	return service, nil
 }
 
+// AuthStatus reports whether the account is authorized or needs to be
+// authorized again.
+func (c *Youtube) AuthStatus() string {
+	return c.tokens.Status()
+}
+
 func (c *Youtube) ValidateToken() (bool, error) {
 	service, err := c.GetService(context.Background())
 	if err != nil {
@@ -153,83 +143,180 @@ func (c *Youtube) ValidateToken() (bool, error) {
 	return service != nil, nil
 }
 
-func (c *Youtube) GetUserSubscriptions(service *youtube.Service) ([]*youtube.Subscription, error) {
-	cacheKey := "youtube_subscriptions"
-
-	if items, ok := c.getFromCache(cacheKey); ok {
-		return items.([]*youtube.Subscription), nil
-	}
-
-	ctx := context.Background()
-
+// GetUserSubscriptions lists every channel the user is subscribed to. The
+// result is not cached, it is the source subscription state is reconciled
+// against.
+func (c *Youtube) GetUserSubscriptions(ctx context.Context, service *youtube.Service) ([]*youtube.Subscription, error) {
 	part := []string{"snippet"}
 	call := service.Subscriptions.List(part)
 	call.Mine(true)
 
 	var channels = make([]*youtube.Subscription, 0)
 
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+		return nil, err
+	}
+
 	if err := call.Pages(ctx, func(page *youtube.SubscriptionListResponse) error {
 		channels = append(channels, page.Items...)
 
-		return nil
+		// Pages fetches the next page once this returns.
+		if page.NextPageToken == "" {
+			return nil
+		}
+
+		return c.beforeRequest(ctx, QuotaCostList)
 	}); err != nil {
 		return nil, err
 	}
 
-	c.storeInCache(cacheKey, channels)
-
 	return channels, nil
 }
 
-func (c *Youtube) GetChannelVideos(service *youtube.Service, channelId string, lastSyncAt *time.Time) ([]*youtube.Activity, error) {
-	cacheKey := "youtube_channel_videos_" + channelId
+type ChannelActivities struct {
+	Videos      []*youtube.Activity
+	ETag        string
+	NotModified bool
+}
 
-	if lastSyncAt == nil {
-		defaultTime := time.Now().Add(-7 * 24 * time.Hour)
-		lastSyncAt = &defaultTime
+// GetChannelActivities lists uploads published after publishedAfter. When etag
+// matches the current first page, YouTube answers with 304 and NotModified is
+// set instead of returning the same uploads again.
+func (c *Youtube) GetChannelActivities(ctx context.Context, service *youtube.Service, channelId string, publishedAfter time.Time, etag string) (ChannelActivities, error) {
+	result := ChannelActivities{
+		Videos: make([]*youtube.Activity, 0),
+		ETag:   etag,
 	}
 
-	if items, ok := c.getFromCache(cacheKey); ok {
-		return items.([]*youtube.Activity), nil
+	call := service.Activities.List([]string{"snippet", "contentDetails"})
+	call.ChannelId(channelId)
+	call.PublishedAfter(publishedAfter.Format(time.RFC3339))
+	call.MaxResults(50)
+
+	pageToken := ""
+	for {
+		call.PageToken(pageToken)
+		if pageToken == "" && etag != "" {
+			call.IfNoneMatch(etag)
+		} else {
+			call.IfNoneMatch("")
+		}
+
+		if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+			return result, err
+		}
+
+		page, err := call.Context(ctx).Do()
+		if err != nil {
+			if pageToken == "" && googleapi.IsNotModified(err) {
+				result.NotModified = true
+				return result, nil
+			}
+
+			return result, err
+		}
+
+		if pageToken == "" {
+			result.ETag = page.Etag
+		}
+
+		for _, item := range page.Items {
+			if item.Snippet.Type != "upload" {
+				continue
+			}
+
+			result.Videos = append(result.Videos, item)
+		}
+
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		pageToken = page.NextPageToken
 	}
+}
 
-	ctx := context.Background()
+// GetChannelUploads returns up to limit of the latest uploads from the channel
+// uploads playlist, newest first. Unlike activities, the playlist is not
+// limited to recent uploads.
+func (c *Youtube) GetChannelUploads(ctx context.Context, service *youtube.Service, channelId string, limit int) ([]*youtube.PlaylistItem, error) {
+	uploads := make([]*youtube.PlaylistItem, 0, limit)
+	if limit <= 0 {
+		return uploads, nil
+	}
 
-	part := []string{"snippet", "contentDetails"}
-	call := service.Activities.List(part)
-	call.ChannelId(channelId)
-	call.PublishedAfter(lastSyncAt.Format(time.RFC3339))
+	playlistId, err := c.getUploadsPlaylistId(ctx, service, channelId)
+	if err != nil || playlistId == "" {
+		return uploads, err
+	}
+
+	call := service.PlaylistItems.List([]string{"snippet", "contentDetails"})
+	call.PlaylistId(playlistId)
+	call.MaxResults(int64(min(limit, 50)))
 
-	var videos = make([]*youtube.Activity, 0)
+	pageToken := ""
+	for {
+		call.PageToken(pageToken)
+
+		if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+			return uploads, err
+		}
+
+		page, err := call.Context(ctx).Do()
+		if err != nil {
+			return uploads, err
+		}
 
-	if err := call.Pages(ctx, func(page *youtube.ActivityListResponse) error {
 		for _, item := range page.Items {
-			if item.Snippet.Type != "upload" || item.Snippet.Description == "" {
+			// Private and deleted videos stay in the playlist without a publish date.
+			if item.ContentDetails == nil || item.ContentDetails.VideoPublishedAt == "" {
 				continue
 			}
 
-			videos = append(videos, item)
+			uploads = append(uploads, item)
+			if len(uploads) >= limit {
+				return uploads, nil
+			}
 		}
 
-		return nil
-	}); err != nil {
-		return videos, err
+		if page.NextPageToken == "" {
+			return uploads, nil
+		}
+		pageToken = page.NextPageToken
 	}
+}
 
-	if len(videos) == 0 {
-		return videos, nil
+// getUploadsPlaylistId derives the uploads playlist from the channel ID, which
+// costs no quota, and only asks the API for channels in another format.
+func (c *Youtube) getUploadsPlaylistId(ctx context.Context, service *youtube.Service, channelId string) (string, error) {
+	if strings.HasPrefix(channelId, "UC") {
+		return "UU" + strings.TrimPrefix(channelId, "UC"), nil
 	}
 
-	c.storeInCache(cacheKey, videos)
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+		return "", err
+	}
 
-	return videos, nil
+	response, err := service.Channels.List([]string{"contentDetails"}).Id(channelId).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.Items) == 0 || response.Items[0].ContentDetails == nil || response.Items[0].ContentDetails.RelatedPlaylists == nil {
+		return "", nil
+	}
+
+	return response.Items[0].ContentDetails.RelatedPlaylists.Uploads, nil
 }
 
 func (c *Youtube) GetChannelByVideoId(service *youtube.Service, videoId string) (*youtube.Channel, error) {
-	cacheKey := "youtube_channel_ids_" + videoId
+	ctx := context.Background()
 
-	if item, ok := c.getFromCache(cacheKey); ok {
-		return item.(*youtube.Channel), nil
+	var cached youtube.Channel
+	if c.getFromCache(ctx, CacheFamilyChannel, videoId, &cached) {
+		return &cached, nil
+	}
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+		return nil, err
 	}
 
 	videoCall := service.Videos.List([]string{"snippet"}).Id(videoId).MaxResults(1)
@@ -245,6 +332,10 @@ func (c *Youtube) GetChannelByVideoId(service *youtube.Service, videoId string)
 	channelID := videoResponse.Items[0].Snippet.ChannelId
 
 	channelCall := service.Channels.List([]string{"snippet"}).Id(channelID).MaxResults(1)
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+		return nil, err
+	}
+
 	channelResponse, err := channelCall.Do()
 	if err != nil {
 		return nil, err
@@ -254,19 +345,24 @@ func (c *Youtube) GetChannelByVideoId(service *youtube.Service, videoId string)
 		return nil, nil
 	}
 
-	c.storeInCache(cacheKey, channelResponse.Items[0])
+	c.storeInCache(ctx, CacheFamilyChannel, videoId, channelResponse.Items[0])
 	return channelResponse.Items[0], nil
 }
 
 func (c *Youtube) GetChannelByName(service *youtube.Service, name string) (*youtube.SearchResultSnippet, error) {
-	cacheKey := "youtube_channel_" + name
+	ctx := context.Background()
 
-	if item, ok := c.getFromCache(cacheKey); ok {
-		return item.(*youtube.SearchResultSnippet), nil
+	var cached youtube.SearchResultSnippet
+	if c.getFromCache(ctx, CacheFamilySearch, name, &cached) {
+		return &cached, nil
 	}
 
 	call := service.Search.List([]string{"snippet"}).Q(name).Type("channel").MaxResults(1)
 
+	if err := c.beforeRequest(ctx, QuotaCostSearch); err != nil {
+		return nil, err
+	}
+
 	response, err := call.Do()
 	if err != nil {
 		return nil, err
@@ -276,59 +372,120 @@ func (c *Youtube) GetChannelByName(service *youtube.Service, name string) (*yout
 		return nil, nil
 	}
 
-	c.storeInCache(cacheKey, response.Items[0].Snippet)
+	c.storeInCache(ctx, CacheFamilySearch, name, response.Items[0].Snippet)
 	return response.Items[0].Snippet, nil
 }
 
-func (c *Youtube) IsShortVideo(service *youtube.Service, video *youtube.Activity) (bool, error) {
-	call := service.Videos.List([]string{"contentDetails"}).Id(video.ContentDetails.Upload.VideoId)
+const ShortVideoMaxDuration = time.Minute
 
-	response, err := call.Do()
-	if err != nil {
-		return false, err
-	}
+// GetVideosDetails loads content and live streaming details for the given
+// videos, batching up to MaxVideoIDsPerRequest IDs per request. Videos that no
+// longer exist or cannot be seen by the user are missing from the result.
+func (c *Youtube) GetVideosDetails(ctx context.Context, service *youtube.Service, videoIds []string) (map[string]*youtube.Video, error) {
+	videos := make(map[string]*youtube.Video, len(videoIds))
 
-	if len(response.Items) == 0 {
-		return false, nil
+	for start := 0; start < len(videoIds); start += MaxVideoIDsPerRequest {
+		batch := videoIds[start:min(start+MaxVideoIDsPerRequest, len(videoIds))]
+
+		call := service.Videos.List([]string{"snippet", "contentDetails", "liveStreamingDetails"}).Id(batch...).MaxResults(MaxVideoIDsPerRequest)
+		if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+			return videos, err
+		}
+
+		response, err := call.Context(ctx).Do()
+		if err != nil {
+			return videos, err
+		}
+
+		for _, video := range response.Items {
+			videos[video.Id] = video
+		}
 	}
 
-	duration, err := time.ParseDuration(parseISO8601Duration(response.Items[0].ContentDetails.Duration))
+	return videos, nil
+}
+
+func ParseVideoDuration(isoDuration string) (time.Duration, error) {
+	duration, err := time.ParseDuration(parseISO8601Duration(isoDuration))
 	if err != nil {
 		log.Printf("[ERROR] Failed to parse duration: %v", err)
-		return false, err
+		return 0, err
+	}
+
+	return duration, nil
+}
+
+func IsShortVideo(duration time.Duration) bool {
+	return duration > 0 && duration <= ShortVideoMaxDuration
+}
+
+// ClassifyVideo returns the content type of a video and, for broadcasts, the
+// time it starts or started.
+func ClassifyVideo(video *youtube.Video) (string, *time.Time) {
+	if video.Snippet == nil {
+		return database.VideoContentTypeVideo, nil
 	}
 
-	if duration > time.Minute {
-		return false, nil
+	var startTime string
+	if details := video.LiveStreamingDetails; details != nil {
+		startTime = details.ScheduledStartTime
+		if details.ActualStartTime != "" {
+			startTime = details.ActualStartTime
+		}
 	}
 
-	return true, nil
+	var startAt *time.Time
+	if parsed, err := time.Parse(time.RFC3339, startTime); err == nil {
+		startAt = &parsed
+	}
+
+	switch video.Snippet.LiveBroadcastContent {
+	case "live":
+		return database.VideoContentTypeLive, startAt
+	case "upcoming":
+		return database.VideoContentTypeUpcoming, startAt
+	default:
+		return database.VideoContentTypeVideo, nil
+	}
 }
 
 func (c *Youtube) IsUserSubscribed(service *youtube.Service, channelId string) (bool, error) {
-	subscriptions, err := c.GetUserSubscriptions(service)
-	if err != nil {
+	ctx := context.Background()
+
+	var isSubscribed bool
+	if c.getFromCache(ctx, CacheFamilySubscription, channelId, &isSubscribed) {
+		return isSubscribed, nil
+	}
+
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
 		return false, err
 	}
 
-	for _, subscription := range subscriptions {
-		if subscription.Snippet.ResourceId.ChannelId == channelId {
-			return true, nil
-		}
+	response, err := service.Subscriptions.List([]string{"id"}).Mine(true).ForChannelId(channelId).Context(ctx).Do()
+	if err != nil {
+		return false, err
 	}
 
-	return false, nil
+	isSubscribed = len(response.Items) > 0
+	c.storeInCache(ctx, CacheFamilySubscription, channelId, isSubscribed)
+
+	return isSubscribed, nil
 }
 
 func (c *Youtube) GetVideoDetails(service *youtube.Service, videoId string) (*youtube.Video, error) {
-	cacheKey := "youtube_video_" + videoId
+	ctx := context.Background()
 
-	if item, ok := c.getFromCache(cacheKey); ok {
-		return item.(*youtube.Video), nil
+	var cached youtube.Video
+	if c.getFromCache(ctx, CacheFamilyVideo, videoId, &cached) {
+		return &cached, nil
 	}
 
 	call := service.Videos.List([]string{"snippet", "contentDetails"}).Id(videoId)
 
+	if err := c.beforeRequest(ctx, QuotaCostList); err != nil {
+		return nil, err
+	}
+
 	response, err := call.Do()
 	if err != nil {
 		return nil, err
@@ -338,11 +495,13 @@ func (c *Youtube) GetVideoDetails(service *youtube.Service, videoId string) (*yo
 		return nil, nil
 	}
 
-	c.storeInCache(cacheKey, response.Items[0])
+	c.storeInCache(ctx, CacheFamilyVideo, videoId, response.Items[0])
 	return response.Items[0], nil
 }
 
-func (c *Youtube) GetAuthURL() (string, error) {
+// GetAuthURL starts an authorization flow identified by state. The PKCE
+// challenge is derived from codeVerifier, which the callback must present.
+func (c *Youtube) GetAuthURL(state, codeVerifier string) (string, error) {
 	b, err := os.ReadFile(c.options.ConfigPath)
 	if err != nil {
 		log.Printf("[ERROR] Unable to read client secret file: %v", err)
@@ -356,8 +515,9 @@ func (c *Youtube) GetAuthURL() (string, error) {
 	}
 
 	return config.AuthCodeURL(
-		"state-token",
+		state,
 		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(codeVerifier),
 		oauth2.SetAuthURLParam("prompt", "consent"),
 		oauth2.SetAuthURLParam("redirect_uri", c.options.RedirectURI),
 	), nil
@@ -393,29 +553,57 @@ func parseISO8601Duration(duration string) string {
 	return duration
 }
 
-type CacheItem struct {
-	Items      interface{}
-	Expiration time.Time
+// QuotaUnits returns the estimated quota units spent by this client since it
+// was created.
+func (c *Youtube) QuotaUnits() int64 {
+	return c.quotaUnits.Load()
 }
 
-func (c *Youtube) getFromCache(key string) (interface{}, bool) {
-	item, ok := c.cache.Load(key)
-	if !ok {
-		return nil, false
+// beforeRequest waits for the rate limiter and counts the quota units of the
+// request about to be made.
+func (c *Youtube) beforeRequest(ctx context.Context, units int64) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
 	}
 
-	cacheItem := item.(CacheItem)
-	if cacheItem.Expiration.Before(time.Now()) {
-		c.cache.Delete(key)
-		return nil, false
+	c.quotaUnits.Add(units)
+
+	return nil
+}
+
+// CacheStats returns the hits and misses of the response cache, or nil when
+// responses are not cached.
+func (c *Youtube) CacheStats() *CacheStats {
+	if c.cache == nil {
+		return nil
 	}
 
-	return cacheItem.Items, true
+	stats := c.cache.Stats()
+	return &stats
 }
 
-func (c *Youtube) storeInCache(key string, value interface{}) {
-	c.cache.Store(key, CacheItem{
-		Items:      value,
-		Expiration: time.Now().Add(1 * time.Hour),
-	})
+// getFromCache decodes a cached response into value. Cache failures are
+// treated as misses, the response is then loaded from the API.
+func (c *Youtube) getFromCache(ctx context.Context, family, key string, value any) bool {
+	if c.cache == nil {
+		return false
+	}
+
+	ok, err := c.cache.Get(ctx, family, key, value)
+	if err != nil {
+		log.Printf("[ERROR] failed to read %s from cache: %s", family, err)
+		return false
+	}
+
+	return ok
+}
+
+func (c *Youtube) storeInCache(ctx context.Context, family, key string, value any) {
+	if c.cache == nil {
+		return
+	}
+
+	if err := c.cache.Set(ctx, family, key, value); err != nil {
+		log.Printf("[ERROR] failed to store %s in cache: %s", family, err)
+	}
 }
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"content-oracle/app/database"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Authorization states of the YouTube account reported by AuthStatus.
+const (
+	YouTubeAuthStatusOK             = "ok"
+	YouTubeAuthStatusUnauthorized   = "unauthorized"
+	YouTubeAuthStatusReauthRequired = "reauth_required"
+)
+
+var (
+	ErrYouTubeUnauthorized   = errors.New("youtube account is not authorized")
+	ErrYouTubeReauthRequired = errors.New("youtube authorization was revoked or expired, re-auth required")
+)
+
+// youtubeTokenStore is the token source of every YouTube client. It refreshes
+// the access token when it expires and persists the token, expiry included,
+// only when it changed. A refresh token rejected by Google switches it to the
+// re-auth required state until a new token is set.
+type youtubeTokenStore struct {
+	mu                 sync.Mutex
+	oauthConfig        *oauth2.Config
+	settingsRepository *database.SettingsRepository
+	token              *oauth2.Token
+	reauthRequired     bool
+}
+
+func newYoutubeTokenStore(oauthConfig *oauth2.Config, settingsRepository *database.SettingsRepository, settings *database.Settings) *youtubeTokenStore {
+	store := &youtubeTokenStore{
+		oauthConfig:        oauthConfig,
+		settingsRepository: settingsRepository,
+	}
+
+	if settings.YoutubeAccessToken == "" && settings.YoutubeRefreshToken == "" {
+		return store
+	}
+
+	store.token = &oauth2.Token{
+		AccessToken:  settings.YoutubeAccessToken,
+		RefreshToken: settings.YoutubeRefreshToken,
+		TokenType:    "Bearer",
+		// Tokens stored before the expiry was persisted are refreshed once.
+		Expiry: time.Now(),
+	}
+	if settings.YoutubeTokenExpiry != nil {
+		store.token.Expiry = *settings.YoutubeTokenExpiry
+	}
+
+	return store
+}
+
+// Token returns a valid access token, refreshing it when needed.
+func (s *youtubeTokenStore) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reauthRequired {
+		return nil, ErrYouTubeReauthRequired
+	}
+
+	if s.token == nil {
+		return nil, ErrYouTubeUnauthorized
+	}
+
+	if s.token.Valid() {
+		return copyToken(s.token), nil
+	}
+
+	if s.token.RefreshToken == "" {
+		s.reauthRequired = true
+		return nil, ErrYouTubeReauthRequired
+	}
+
+	token, err := s.oauthConfig.TokenSource(context.Background(), s.token).Token()
+	if err != nil {
+		if isRevokedTokenError(err) {
+			log.Printf("[WARN] YouTube refresh token was rejected: %s", err)
+			s.reauthRequired = true
+			return nil, fmt.Errorf("%w: %w", ErrYouTubeReauthRequired, err)
+		}
+
+		log.Printf("[ERROR] Unable to refresh token: %v", err)
+		return nil, err
+	}
+
+	if err := s.save(token); err != nil {
+		log.Printf("[ERROR] Unable to update youtube settings: %v", err)
+	}
+	log.Printf("[INFO] YouTube access token refreshed, expires at %s", token.Expiry.Local())
+
+	return copyToken(token), nil
+}
+
+// Set replaces the token after the user authorized the account again.
+func (s *youtubeTokenStore) Set(token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reauthRequired = false
+
+	return s.save(token)
+}
+
+func (s *youtubeTokenStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reauthRequired = false
+
+	return s.save(nil)
+}
+
+func (s *youtubeTokenStore) Status() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.reauthRequired:
+		return YouTubeAuthStatusReauthRequired
+	case s.token == nil:
+		return YouTubeAuthStatusUnauthorized
+	default:
+		return YouTubeAuthStatusOK
+	}
+}
+
+// save stores token unless it equals the current one. The caller holds mu.
+func (s *youtubeTokenStore) save(token *oauth2.Token) error {
+	if isSameToken(s.token, token) {
+		return nil
+	}
+
+	settings := database.Settings{}
+	if token != nil {
+		settings.YoutubeAccessToken = token.AccessToken
+		settings.YoutubeRefreshToken = token.RefreshToken
+		if !token.Expiry.IsZero() {
+			expiry := token.Expiry
+			settings.YoutubeTokenExpiry = &expiry
+		}
+	}
+
+	if err := s.settingsRepository.SetYoutubeSettings(settings); err != nil {
+		return err
+	}
+
+	s.token = copyToken(token)
+
+	return nil
+}
+
+func isSameToken(a, b *oauth2.Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.AccessToken == b.AccessToken && a.RefreshToken == b.RefreshToken && a.Expiry.Equal(b.Expiry)
+}
+
+func copyToken(token *oauth2.Token) *oauth2.Token {
+	if token == nil {
+		return nil
+	}
+
+	tokenCopy := *token
+	return &tokenCopy
+}
+
+// isRevokedTokenError reports whether Google rejected the refresh token
+// itself, as opposed to a temporary failure of the token endpoint.
+func isRevokedTokenError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+
+	if retrieveErr.ErrorCode == "invalid_grant" || retrieveErr.ErrorCode == "unauthorized_client" {
+		return true
+	}
+
+	return retrieveErr.Response != nil &&
+		(retrieveErr.Response.StatusCode == http.StatusBadRequest || retrieveErr.Response.StatusCode == http.StatusUnauthorized)
+}
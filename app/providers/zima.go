@@ -2,6 +2,7 @@ package providers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -57,7 +58,7 @@ type invokeActionResponse struct {
 	Response []ZimaContent `json:"response"`
 }
 
-func (c *Zima) GetContent(includePlayback bool, applicationName string) ([]ZimaContent, error) {
+func (c *Zima) GetContent(ctx context.Context, includePlayback bool, applicationName string) ([]ZimaContent, error) {
 	reqPayload := getContentActionPayload{
 		Name: "content-collector-history",
 		Args: getContentActionArgs{
@@ -66,7 +67,7 @@ func (c *Zima) GetContent(includePlayback bool, applicationName string) ([]ZimaC
 		},
 	}
 
-	resp, err := InvokeAction[invokeActionResponse, getContentActionPayload](c.url, reqPayload)
+	resp, err := InvokeAction[invokeActionResponse, getContentActionPayload](ctx, c.url, reqPayload)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +82,7 @@ type OpenUrlActionPayload struct {
 	} `json:"args"`
 }
 
-func (c *Zima) OpenUrl(url string) error {
+func (c *Zima) OpenUrl(ctx context.Context, url string) error {
 	reqPayload := OpenUrlActionPayload{
 		Name: "streams-start",
 		Args: struct {
@@ -89,7 +90,7 @@ func (c *Zima) OpenUrl(url string) error {
 		}{url},
 	}
 
-	_, err := InvokeAction[interface{}, OpenUrlActionPayload](c.url, reqPayload)
+	_, err := InvokeAction[interface{}, OpenUrlActionPayload](ctx, c.url, reqPayload)
 	if err != nil {
 		return err
 	}
@@ -97,7 +98,7 @@ func (c *Zima) OpenUrl(url string) error {
 	return nil
 }
 
-func InvokeAction[T any, P any](url string, payload P) (*T, error) {
+func InvokeAction[T any, P any](ctx context.Context, url string, payload P) (*T, error) {
 	bodyBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
@@ -106,7 +107,13 @@ func InvokeAction[T any, P any](url string, payload P) (*T, error) {
 	invokeUrl := url + "/discovery/invoke"
 
 	reader := bytes.NewReader(bodyBytes)
-	resp, err := http.Post(invokeUrl, "application/json", reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, invokeUrl, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +25,13 @@ func (c *Client) Start(jobFun interface{}, params ...interface{}) error {
 	return nil
 }
 
+// Every adds a job that runs at the given interval, first after one interval
+// has passed.
+func (c *Client) Every(interval time.Duration, jobFun interface{}, params ...interface{}) error {
+	_, err := c.Scheduler.Every(interval).WaitForSchedule().Do(jobFun, params...)
+	return err
+}
+
 func (c *Client) NextRun() (*gocron.Job, time.Time) {
 	return c.Scheduler.NextRun()
 }
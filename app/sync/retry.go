@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/googleapi"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retry calls fn until it succeeds, fails with an error that is not worth
+// retrying, or runs out of attempts. The delay doubles after every attempt and
+// gets some jitter so that parallel workers do not retry in lockstep.
+func (c *YouTubeProvider) retry(ctx context.Context, fn func() error) error {
+	delay := c.retryDelay
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || attempt >= c.retries || !isRetryable(err) {
+			return err
+		}
+
+		wait := delay + rand.N(delay/2+1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+	}
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.Code)
+	}
+
+	var feedErr *FeedStatusError
+	if errors.As(err, &feedErr) {
+		return isRetryableStatus(feedErr.StatusCode)
+	}
+
+	return true
+}
+
+// isQuotaExceeded reports whether the Data API refused a call because the
+// project quota or rate limit is used up, which no retry within the run fixes.
+func isQuotaExceeded(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return false
+	}
+
+	for _, item := range apiErr.Errors {
+		if item.Reason == "quotaExceeded" || item.Reason == "rateLimitExceeded" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
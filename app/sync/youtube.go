@@ -1,115 +1,939 @@
 package sync
 
 import (
+	"content-oracle/app/content"
 	"content-oracle/app/database"
+	"content-oracle/app/events"
 	"content-oracle/app/providers"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/go-pkgz/syncs"
+	"google.golang.org/api/youtube/v3"
 	"log"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const YoutubeApplicationName = "YouTube (com.google.ios.youtube)"
 
+const (
+	SyncRunSourceYouTube        = "youtube"
+	SyncRunSourceYouTubeRefresh = "youtube_refresh"
+)
+
+const (
+	DefaultDailyQuota         = 10000
+	DefaultQuotaSlowdownRatio = 0.8
+	DefaultSlowSyncInterval   = 6 * time.Hour
+	DefaultConcurrency        = 4
+	DefaultRetries            = 3
+	DefaultRetryDelay         = time.Second
+	DefaultBackfillVideos     = 25
+	DefaultRefreshLookback    = 30 * 24 * time.Hour
+	DefaultRefreshLimit       = 500
+)
+
+// progressInterval is the least time between two saved progress updates of a
+// run.
+const progressInterval = time.Second
+
 type YouTubeProvider struct {
-	youtubeRepository *database.YouTubeRepository
-	youtubeClient     *providers.Youtube
-	zimaClient        *providers.Zima
+	youtubeRepository     *database.YouTubeRepository
+	youtubeSyncRepository *database.YouTubeSyncRepository
+	syncRunRepository     *database.SyncRunRepository
+	youtubeClient         *providers.Youtube
+	youtubeFeed           *YouTubeFeed
+	zimaClient            *providers.Zima
+	eventBroker           *events.Broker
+	feedCache             Invalidator
+	dailyQuota            int64
+	quotaSlowdownRatio    float64
+	slowSyncInterval      time.Duration
+	concurrency           int
+	retries               int
+	retryDelay            time.Duration
+	backfillVideos        int
+	refreshLookback       time.Duration
+	refreshLimit          int
+	recordedQuotaUnits    int64
+	running               atomic.Bool
+	// refreshQueued is set when the refresh fires during another run, the
+	// refresh then starts once that run ends.
+	refreshQueued atomic.Bool
+	// quotaExceeded is set when the Data API refuses calls during a run, the
+	// rest of the run then uses channel feeds.
+	quotaExceeded atomic.Bool
+	// runMu guards the counters and errors of the current run, which are
+	// updated by several workers.
+	runMu          sync.Mutex
+	lastProgressAt time.Time
 }
 
 type YouTubeProviderOptions struct {
-	YoutubeRepository *database.YouTubeRepository
-	YoutubeClient     *providers.Youtube
-	ZimaClient        *providers.Zima
+	YoutubeRepository     *database.YouTubeRepository
+	YouTubeSyncRepository *database.YouTubeSyncRepository
+	SyncRunRepository     *database.SyncRunRepository
+	YoutubeClient         *providers.Youtube
+	YouTubeFeed           *YouTubeFeed
+	ZimaClient            *providers.Zima
+	EventBroker           *events.Broker
+	FeedCache             Invalidator
+	DailyQuota            int64
+	QuotaSlowdownRatio    float64
+	SlowSyncInterval      time.Duration
+	Concurrency           int
+	Retries               int
+	RetryDelay            time.Duration
+	// BackfillVideos is how many recent uploads are loaded for a channel that
+	// is synced for the first time.
+	BackfillVideos int
+	// RefreshLookback and RefreshLimit bound the videos re-checked by a refresh.
+	RefreshLookback time.Duration
+	RefreshLimit    int
 }
 
 func NewYouTubeProvider(options YouTubeProviderOptions) *YouTubeProvider {
+	dailyQuota := options.DailyQuota
+	if dailyQuota <= 0 {
+		dailyQuota = DefaultDailyQuota
+	}
+
+	quotaSlowdownRatio := options.QuotaSlowdownRatio
+	if quotaSlowdownRatio <= 0 || quotaSlowdownRatio > 1 {
+		quotaSlowdownRatio = DefaultQuotaSlowdownRatio
+	}
+
+	slowSyncInterval := options.SlowSyncInterval
+	if slowSyncInterval <= 0 {
+		slowSyncInterval = DefaultSlowSyncInterval
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	retries := options.Retries
+	if retries < 0 {
+		retries = DefaultRetries
+	}
+
+	retryDelay := options.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = DefaultRetryDelay
+	}
+
+	backfillVideos := options.BackfillVideos
+	if backfillVideos < 0 {
+		backfillVideos = DefaultBackfillVideos
+	}
+
+	refreshLookback := options.RefreshLookback
+	if refreshLookback <= 0 {
+		refreshLookback = DefaultRefreshLookback
+	}
+
+	refreshLimit := options.RefreshLimit
+	if refreshLimit <= 0 {
+		refreshLimit = DefaultRefreshLimit
+	}
+
+	youtubeFeed := options.YouTubeFeed
+	if youtubeFeed == nil {
+		youtubeFeed = NewYouTubeFeed(YouTubeFeedOptions{})
+	}
+
 	return &YouTubeProvider{
-		youtubeRepository: options.YoutubeRepository,
-		youtubeClient:     options.YoutubeClient,
-		zimaClient:        options.ZimaClient,
+		youtubeRepository:     options.YoutubeRepository,
+		youtubeSyncRepository: options.YouTubeSyncRepository,
+		syncRunRepository:     options.SyncRunRepository,
+		youtubeClient:         options.YoutubeClient,
+		youtubeFeed:           youtubeFeed,
+		zimaClient:            options.ZimaClient,
+		eventBroker:           options.EventBroker,
+		feedCache:             options.FeedCache,
+		dailyQuota:            dailyQuota,
+		quotaSlowdownRatio:    quotaSlowdownRatio,
+		slowSyncInterval:      slowSyncInterval,
+		concurrency:           concurrency,
+		retries:               retries,
+		retryDelay:            retryDelay,
+		backfillVideos:        backfillVideos,
+		refreshLookback:       refreshLookback,
+		refreshLimit:          refreshLimit,
 	}
 }
 
+// Invalidator is implemented by caches of responses built from synced videos.
+type Invalidator interface {
+	Invalidate()
+}
+
+type pendingVideo struct {
+	id          string
+	channelID   string
+	title       string
+	thumbnail   string
+	publishedAt time.Time
+	// membersOnly is set when the listing shows the upload is for channel
+	// members only.
+	membersOnly bool
+}
+
+// isMembersOnlyListing checks the description of an activity or playlist item.
+// Members-only uploads are listed with an empty one, so an empty description
+// is taken to mean the upload is for channel members only.
+func isMembersOnlyListing(description string) bool {
+	return description == ""
+}
+
+var ErrSyncRunning = errors.New("sync is already running")
+
+type SyncOptions struct {
+	TriggeredBy string
+	// ChannelID limits the run to a single channel.
+	ChannelID string
+	// Channels limits the run to several channels.
+	Channels []string
+	// Since overrides the last published video as the point uploads are
+	// looked up from.
+	Since time.Time
+}
+
+func (o SyncOptions) channels() []string {
+	if o.ChannelID != "" {
+		return []string{o.ChannelID}
+	}
+
+	return o.Channels
+}
+
+// Do runs a full sync and records it as a sync run. It is the scheduled job,
+// so a run that is still in progress makes it skip instead of fail.
 func (c *YouTubeProvider) Do(ctx context.Context) error {
-	youtubeService, err := c.youtubeClient.GetService(ctx)
+	run, err := c.startRun(ctx, SyncRunSourceYouTube, SyncOptions{TriggeredBy: database.SyncRunTriggerSchedule})
 	if err != nil {
-		log.Printf("[ERROR] failed to get youtube service: %s", err)
+		if errors.Is(err, ErrSyncRunning) {
+			log.Printf("[WARN] Skipping scheduled YouTube sync: %s", err)
+			return nil
+		}
+
 		return err
 	}
+	defer c.endRun(ctx)
 
-	channels, err := c.prepareChannelsList(ctx, youtubeService)
+	err = c.sync(ctx, run, nil)
+	c.finishRun(ctx, run, err)
+
+	return err
+}
+
+// Start begins a run in the background and returns it right away so that its
+// progress can be polled. It fails with ErrSyncRunning while another run is
+// in progress.
+func (c *YouTubeProvider) Start(ctx context.Context, opt SyncOptions) (database.SyncRun, error) {
+	run, err := c.startRun(ctx, SyncRunSourceYouTube, opt)
 	if err != nil {
+		return database.SyncRun{}, err
+	}
+
+	// The returned copy is not touched by the sync goroutine.
+	startedRun := *run
+
+	go func() {
+		defer c.endRun(ctx)
+
+		err := c.sync(ctx, run, opt.channels())
+		c.finishRun(ctx, run, err)
+	}()
+
+	return startedRun, nil
+}
+
+// Backfill starts a run that loads the recent uploads of channels that were
+// never synced, such as newly ranked ones. Channels that were synced before
+// only get the regular incremental sync.
+func (c *YouTubeProvider) Backfill(ctx context.Context, channels []string) (database.SyncRun, error) {
+	return c.Start(ctx, SyncOptions{
+		TriggeredBy: database.SyncRunTriggerBackfill,
+		Channels:    channels,
+	})
+}
+
+func (c *YouTubeProvider) startRun(ctx context.Context, source string, opt SyncOptions) (*database.SyncRun, error) {
+	if !c.running.CompareAndSwap(false, true) {
+		return nil, ErrSyncRunning
+	}
+
+	run := database.SyncRun{
+		Source:      source,
+		TriggeredBy: opt.TriggeredBy,
+		ChannelID:   opt.ChannelID,
+	}
+	if !opt.Since.IsZero() {
+		run.Since = &opt.Since
+	}
+
+	createdRun, err := c.syncRunRepository.Create(ctx, run)
+	if err != nil {
+		c.running.Store(false)
+		return nil, err
+	}
+
+	return createdRun, nil
+}
+
+// endRun releases the run guard and starts a refresh that was queued while
+// the run was in progress.
+func (c *YouTubeProvider) endRun(ctx context.Context) {
+	c.running.Store(false)
+
+	if c.refreshQueued.CompareAndSwap(true, false) {
+		go func() {
+			if err := c.Refresh(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("[ERROR] Queued YouTube refresh failed: %s", err)
+			}
+		}()
+	}
+}
+
+// sync syncs the given channels, or every ranked and watched channel when
+// channels is empty.
+func (c *YouTubeProvider) sync(ctx context.Context, run *database.SyncRun, channels []string) error {
+	spentToday, err := c.youtubeSyncRepository.GetQuotaUnits(ctx, quotaDay(time.Now()))
+	if err != nil {
+		log.Printf("[ERROR] failed to get spent quota: %s", err)
 		return err
 	}
 
-	log.Printf("[INFO] Found %d channels to sync", len(channels))
+	c.quotaExceeded.Store(false)
 
-	for _, channelID := range channels {
-		channelLastPublishedAt, err := c.youtubeRepository.GetChannelLastPublishedAt(channelID)
+	// Without a token or quota the Data API cannot be used, the channel feeds
+	// still list new uploads.
+	var youtubeService *providers.Service
+	switch {
+	case !c.hasAuthToken():
+		log.Printf("[WARN] YouTube is not authorized, syncing from channel feeds")
+	case spentToday+c.unrecordedQuotaUnits() >= c.dailyQuota:
+		log.Printf("[WARN] YouTube quota budget of %d units is used up, syncing from channel feeds", c.dailyQuota)
+	default:
+		youtubeService, err = c.youtubeClient.GetService(ctx)
 		if err != nil {
-			log.Printf("[ERROR] failed to get channel last sync at: %s", err)
-			continue
+			log.Printf("[ERROR] failed to get youtube service, syncing from channel feeds: %s", err)
+			c.recordError(ctx, run, "", err)
+			youtubeService = nil
 		}
+	}
 
-		// Add a minute to the last published at time to avoid getting the same video again
-		if channelLastPublishedAt != nil && !channelLastPublishedAt.IsZero() {
-			updatedTime := channelLastPublishedAt.Add(time.Minute)
-			*channelLastPublishedAt = updatedTime
+	// A full run first picks up subscription changes, a failure there does
+	// not stop the channels from being synced.
+	if len(channels) == 0 && youtubeService != nil {
+		if _, err := c.reconcileSubscriptions(ctx, youtubeService); err != nil {
+			c.recordError(ctx, run, "", err)
 		}
+	}
 
-		channelVideos, err := c.youtubeClient.GetChannelVideos(youtubeService, channelID, channelLastPublishedAt)
+	if len(channels) == 0 {
+		channels, err = c.prepareChannelsList(ctx, youtubeService)
 		if err != nil {
-			log.Printf("[ERROR] failed to get channel videos: %s", err)
-			continue
+			return err
 		}
+	}
+
+	log.Printf("[INFO] Found %d channels to sync", len(channels))
+
+	insertedVideos := make([]database.YouTubeVideo, 0)
+	feedChannels := channels
 
-		for _, channelVideo := range channelVideos {
-			id := channelVideo.ContentDetails.Upload.VideoId
-			video, err := c.youtubeRepository.GetVideoByID(id)
-			if err != nil {
-				log.Printf("[ERROR] Error getting video by id: %s", err)
-				continue
+	if youtubeService != nil {
+		var apiVideos []database.YouTubeVideo
+		apiVideos, feedChannels = c.syncFromAPI(ctx, run, youtubeService, channels, spentToday)
+		insertedVideos = append(insertedVideos, apiVideos...)
+	}
+
+	// Feed videos are published by the refresh, once their details are loaded.
+	if len(feedChannels) > 0 {
+		log.Printf("[INFO] Syncing %d channels from channel feeds", len(feedChannels))
+		c.syncFromFeed(ctx, run, feedChannels)
+	}
+
+	log.Printf("[INFO] Finished syncing YouTube")
+
+	if len(insertedVideos) > 0 && c.eventBroker != nil {
+		c.eventBroker.Publish(events.TypeYouTubeVideosAdded, c.videosToContent(insertedVideos))
+	}
+
+	return nil
+}
+
+// videosToContent joins the channel of each video and converts it to content,
+// the shape the other feed events are published in.
+func (c *YouTubeProvider) videosToContent(videos []database.YouTubeVideo) []content.Content {
+	channels := make(map[string]*database.YouTubeChannel)
+	contentList := make([]content.Content, 0, len(videos))
+
+	for _, video := range videos {
+		channel, ok := channels[video.ChannelID]
+		if !ok {
+			var err error
+			if channel, err = c.youtubeRepository.GetChannelByID(video.ChannelID); err != nil {
+				log.Printf("[ERROR] failed to get channel %s: %s", video.ChannelID, err)
 			}
+			channels[video.ChannelID] = channel
+		}
 
-			if video != nil {
-				continue
+		if channel != nil {
+			video.Channel = *channel
+		}
+
+		contentList = append(contentList, content.YoutubeVideoToContent(video, videoCategory(video, channel)))
+	}
+
+	return contentList
+}
+
+// videoCategory returns the default category of the feed row the video shows
+// up in.
+func videoCategory(video database.YouTubeVideo, channel *database.YouTubeChannel) string {
+	switch {
+	case video.ContentType == database.VideoContentTypeLive:
+		return content.YouTubeLiveDefaultCategory
+	case video.ContentType == database.VideoContentTypeUpcoming:
+		return content.YouTubePremieresDefaultCategory
+	case channel != nil && !channel.IsSubscribed:
+		return content.YouTubeUnsubscribeChannelsDefaultCategory
+	default:
+		return content.YouTubeSubscriptionDefaultCategory
+	}
+}
+
+type apiChannelResult struct {
+	skipped     bool
+	backfilled  bool
+	channelSync *database.YouTubeChannelSync
+	videos      []pendingVideo
+}
+
+// syncFromAPI syncs channels through the Data API. It returns the inserted
+// videos and the channels it left out to save quota.
+func (c *YouTubeProvider) syncFromAPI(ctx context.Context, run *database.SyncRun, youtubeService *providers.Service, channels []string, spentToday int64) ([]database.YouTubeVideo, []string) {
+	var mu sync.Mutex
+	pendingVideos := make([]pendingVideo, 0)
+	results := make([]apiChannelResult, 0)
+	skippedChannels := make([]string, 0)
+
+	wg := syncs.NewSizedGroup(c.concurrency)
+	for _, channelID := range channels {
+		wg.Go(func(_ context.Context) {
+			result := c.syncChannelFromAPI(ctx, run, youtubeService, channelID, spentToday)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if result.skipped {
+				skippedChannels = append(skippedChannels, channelID)
+				return
 			}
-			log.Printf("[INFO] Video does not exist: %s", id)
 
-			isShorts, err := c.youtubeClient.IsShortVideo(youtubeService, channelVideo)
-			if err != nil {
-				log.Printf("[ERROR] Error checking if video is short: %s", err)
-				continue
+			if result.channelSync != nil {
+				results = append(results, result)
 			}
+			pendingVideos = append(pendingVideos, result.videos...)
+		})
+	}
+	wg.Wait()
 
-			url := fmt.Sprintf("https://www.youtube.com/watch?v=%s", id)
-			publishedAt, err := time.Parse(time.RFC3339, channelVideo.Snippet.PublishedAt)
-			if err != nil {
-				log.Printf("[ERROR] Error parsing published at: %s", err)
-				continue
+	insertedVideos, err := c.createVideos(ctx, run, youtubeService, pendingVideos)
+	if isQuotaExceeded(err) {
+		// Uploads that got no details are inserted from the feeds instead.
+		for _, result := range results {
+			if len(result.videos) > 0 {
+				skippedChannels = append(skippedChannels, result.channelSync.ChannelID)
 			}
+		}
+	}
 
-			err = c.youtubeRepository.CreateVideo(database.YouTubeVideo{
-				Title:       channelVideo.Snippet.Title,
-				ChannelID:   channelVideo.Snippet.ChannelId,
-				Thumbnail:   channelVideo.Snippet.Thumbnails.Medium.Url,
-				PublishedAt: publishedAt,
-				IsShorts:    isShorts,
-				URL:         url,
-				ID:          id,
-			})
-			if err != nil {
-				log.Printf("[ERROR] Error creating video: %s", err)
+	if len(skippedChannels) > 0 {
+		log.Printf("[WARN] YouTube quota is running low, %d channels are left to channel feeds", len(skippedChannels))
+	}
+
+	if err != nil {
+		// Drop the ETags and backfills so that uploads without details are
+		// listed again next run.
+		for _, result := range results {
+			result.channelSync.ETag = ""
+			if result.backfilled {
+				result.channelSync.BackfilledAt = nil
 			}
 		}
 	}
 
-	log.Printf("[INFO] Finished syncing YouTube")
+	for _, result := range results {
+		if err := c.youtubeSyncRepository.SaveChannelSync(ctx, *result.channelSync); err != nil {
+			log.Printf("[ERROR] failed to save channel sync: %s", err)
+		}
+	}
 
-	return nil
+	return insertedVideos, skippedChannels
+}
+
+func (c *YouTubeProvider) syncChannelFromAPI(ctx context.Context, run *database.SyncRun, youtubeService *providers.Service, channelID string, spentToday int64) apiChannelResult {
+	spent := spentToday + c.unrecordedQuotaUnits()
+	if spent >= c.dailyQuota || c.quotaExceeded.Load() {
+		return apiChannelResult{skipped: true}
+	}
+
+	channelSync, err := c.youtubeSyncRepository.GetChannelSync(ctx, channelID)
+	if err != nil {
+		c.recordError(ctx, run, channelID, err)
+		return apiChannelResult{}
+	}
+
+	if channelSync == nil {
+		channelSync = &database.YouTubeChannelSync{ChannelID: channelID}
+	}
+
+	// Close to the budget only channels that were not checked recently use
+	// the API. Channels that were asked for explicitly are never skipped.
+	isExplicit := run.ChannelID != "" || run.TriggeredBy == database.SyncRunTriggerBackfill
+	isSlowedDown := float64(spent) >= float64(c.dailyQuota)*c.quotaSlowdownRatio
+	if isSlowedDown && !isExplicit && time.Since(channelSync.LastCheckedAt) < c.slowSyncInterval {
+		return apiChannelResult{skipped: true}
+	}
+
+	if channelSync.BackfilledAt == nil && run.Since == nil && c.backfillVideos > 0 {
+		return c.backfillChannelFromAPI(ctx, run, youtubeService, channelSync)
+	}
+
+	publishedAfter, err := c.channelPublishedAfter(channelID, run.Since)
+	if err != nil {
+		c.recordError(ctx, run, channelID, err)
+		return apiChannelResult{}
+	}
+
+	// The ETag belongs to the regular incremental listing.
+	etag := channelSync.ETag
+	if run.Since != nil {
+		etag = ""
+	}
+
+	var activities providers.ChannelActivities
+	err = c.retry(ctx, func() error {
+		var err error
+		activities, err = c.youtubeClient.GetChannelActivities(ctx, youtubeService, channelID, publishedAfter, etag)
+		return err
+	})
+	if err != nil {
+		if c.handleQuotaExceeded(ctx, run, err) {
+			return apiChannelResult{skipped: true}
+		}
+
+		log.Printf("[ERROR] failed to get channel videos: %s", err)
+		c.recordError(ctx, run, channelID, err)
+		return apiChannelResult{}
+	}
+
+	c.addScannedChannel(ctx, run)
+
+	channelSync.LastCheckedAt = time.Now()
+	if activities.NotModified {
+		return apiChannelResult{channelSync: channelSync}
+	}
+
+	if run.Since == nil {
+		channelSync.ETag = activities.ETag
+	}
+
+	videos := make([]pendingVideo, 0)
+	for _, channelVideo := range activities.Videos {
+		id := channelVideo.ContentDetails.Upload.VideoId
+		if exists, err := c.videoExists(id); err != nil || exists {
+			continue
+		}
+		log.Printf("[INFO] Video does not exist: %s", id)
+
+		publishedAt, err := time.Parse(time.RFC3339, channelVideo.Snippet.PublishedAt)
+		if err != nil {
+			log.Printf("[ERROR] Error parsing published at: %s", err)
+			continue
+		}
+
+		videos = append(videos, pendingVideo{
+			id:          id,
+			channelID:   channelVideo.Snippet.ChannelId,
+			title:       channelVideo.Snippet.Title,
+			thumbnail:   mediumThumbnail(channelVideo.Snippet.Thumbnails),
+			publishedAt: publishedAt,
+			membersOnly: isMembersOnlyListing(channelVideo.Snippet.Description),
+		})
+	}
+
+	return apiChannelResult{channelSync: channelSync, videos: videos}
+}
+
+// backfillChannelFromAPI loads the latest uploads of a channel that was never
+// synced. Activities only reach back to the last published video, which a new
+// channel does not have, so the uploads playlist is used instead.
+func (c *YouTubeProvider) backfillChannelFromAPI(ctx context.Context, run *database.SyncRun, youtubeService *providers.Service, channelSync *database.YouTubeChannelSync) apiChannelResult {
+	channelID := channelSync.ChannelID
+
+	var uploads []*youtube.PlaylistItem
+	err := c.retry(ctx, func() error {
+		var err error
+		uploads, err = c.youtubeClient.GetChannelUploads(ctx, youtubeService, channelID, c.backfillVideos)
+		return err
+	})
+	if err != nil {
+		if c.handleQuotaExceeded(ctx, run, err) {
+			return apiChannelResult{skipped: true}
+		}
+
+		log.Printf("[ERROR] failed to get channel uploads: %s", err)
+		c.recordError(ctx, run, channelID, err)
+		return apiChannelResult{}
+	}
+
+	c.addScannedChannel(ctx, run)
+
+	// The next run lists activities from the newest backfilled video on.
+	now := time.Now()
+	channelSync.ETag = ""
+	channelSync.LastCheckedAt = now
+	channelSync.BackfilledAt = &now
+
+	videos := make([]pendingVideo, 0)
+	for _, upload := range uploads {
+		id := upload.ContentDetails.VideoId
+		if exists, err := c.videoExists(id); err != nil || exists {
+			continue
+		}
+
+		publishedAt, err := time.Parse(time.RFC3339, upload.ContentDetails.VideoPublishedAt)
+		if err != nil {
+			log.Printf("[ERROR] Error parsing published at: %s", err)
+			continue
+		}
+
+		videos = append(videos, pendingVideo{
+			id:          id,
+			channelID:   channelID,
+			title:       upload.Snippet.Title,
+			thumbnail:   mediumThumbnail(upload.Snippet.Thumbnails),
+			publishedAt: publishedAt,
+			membersOnly: isMembersOnlyListing(upload.Snippet.Description),
+		})
+	}
+
+	log.Printf("[INFO] Backfilling %d videos of channel %s", len(videos), channelID)
+
+	return apiChannelResult{backfilled: true, channelSync: channelSync, videos: videos}
+}
+
+// handleQuotaExceeded switches the rest of the run to channel feeds when the
+// Data API refused err for quota reasons. Only the first refusal is recorded.
+func (c *YouTubeProvider) handleQuotaExceeded(ctx context.Context, run *database.SyncRun, err error) bool {
+	if !isQuotaExceeded(err) {
+		return false
+	}
+
+	if c.quotaExceeded.CompareAndSwap(false, true) {
+		log.Printf("[WARN] YouTube API quota is exceeded, syncing the remaining channels from channel feeds")
+		c.recordError(ctx, run, "", err)
+	}
+
+	return true
+}
+
+func mediumThumbnail(thumbnails *youtube.ThumbnailDetails) string {
+	if thumbnails == nil || thumbnails.Medium == nil {
+		return ""
+	}
+
+	return thumbnails.Medium.Url
+}
+
+// syncFromFeed syncs channels from their public Atom feeds. Feed entries have
+// no duration and do not tell shorts or broadcasts apart, so these videos are
+// stored as pending and only shown once the refresh loaded their details.
+func (c *YouTubeProvider) syncFromFeed(ctx context.Context, run *database.SyncRun, channels []string) {
+	wg := syncs.NewSizedGroup(c.concurrency)
+	for _, channelID := range channels {
+		wg.Go(func(_ context.Context) {
+			c.syncChannelFromFeed(ctx, run, channelID)
+		})
+	}
+	wg.Wait()
+}
+
+func (c *YouTubeProvider) syncChannelFromFeed(ctx context.Context, run *database.SyncRun, channelID string) {
+	publishedAfter, err := c.channelPublishedAfter(channelID, run.Since)
+	if err != nil {
+		c.recordError(ctx, run, channelID, err)
+		return
+	}
+
+	var feedVideos []FeedVideo
+	err = c.retry(ctx, func() error {
+		var err error
+		feedVideos, err = c.youtubeFeed.GetChannelVideos(ctx, channelID, publishedAfter)
+		return err
+	})
+	if err != nil {
+		log.Printf("[ERROR] failed to get channel feed: %s", err)
+		c.recordError(ctx, run, channelID, err)
+		return
+	}
+
+	c.addScannedChannel(ctx, run)
+
+	for _, feedVideo := range feedVideos {
+		if exists, err := c.videoExists(feedVideo.ID); err != nil || exists {
+			continue
+		}
+		log.Printf("[INFO] Video does not exist: %s", feedVideo.ID)
+
+		newVideo := database.YouTubeVideo{
+			Title:       feedVideo.Title,
+			ChannelID:   channelID,
+			Thumbnail:   feedVideo.Thumbnail,
+			PublishedAt: feedVideo.PublishedAt,
+			URL:         fmt.Sprintf("https://www.youtube.com/watch?v=%s", feedVideo.ID),
+			ID:          feedVideo.ID,
+			ContentType: database.VideoContentTypePending,
+		}
+
+		if err := c.youtubeRepository.CreateVideo(newVideo); err != nil {
+			c.recordError(ctx, run, channelID, err)
+			continue
+		}
+
+		c.addInsertedVideo(ctx, run)
+	}
+}
+
+func (c *YouTubeProvider) channelPublishedAfter(channelID string, since *time.Time) (time.Time, error) {
+	if since != nil {
+		return *since, nil
+	}
+
+	channelLastPublishedAt, err := c.youtubeRepository.GetChannelLastPublishedAt(channelID)
+	if err != nil {
+		log.Printf("[ERROR] failed to get channel last sync at: %s", err)
+		return time.Time{}, err
+	}
+
+	// Add a minute to the last published at time to avoid getting the same video again
+	if channelLastPublishedAt != nil && !channelLastPublishedAt.IsZero() {
+		return channelLastPublishedAt.Add(time.Minute), nil
+	}
+
+	return time.Now().Add(-7 * 24 * time.Hour), nil
+}
+
+func (c *YouTubeProvider) videoExists(id string) (bool, error) {
+	video, err := c.youtubeRepository.GetVideoByID(id)
+	if err != nil {
+		log.Printf("[ERROR] Error getting video by id: %s", err)
+		return false, err
+	}
+
+	return video != nil, nil
+}
+
+// hasAuthToken reports whether the account is authorized. A revoked token
+// counts as missing, so syncs fall back to channel feeds until re-auth.
+func (c *YouTubeProvider) hasAuthToken() bool {
+	return c.youtubeClient.AuthStatus() == providers.YouTubeAuthStatusOK
+}
+
+// createVideos loads durations for all pending uploads in batches and stores
+// the videos. It returns an error when some details could not be loaded.
+func (c *YouTubeProvider) createVideos(ctx context.Context, run *database.SyncRun, youtubeService *providers.Service, pendingVideos []pendingVideo) ([]database.YouTubeVideo, error) {
+	insertedVideos := make([]database.YouTubeVideo, 0)
+	if len(pendingVideos) == 0 {
+		return insertedVideos, nil
+	}
+
+	ids := make([]string, 0, len(pendingVideos))
+	for _, pending := range pendingVideos {
+		ids = append(ids, pending.id)
+	}
+
+	var details map[string]*youtube.Video
+	detailsErr := c.retry(ctx, func() error {
+		var err error
+		details, err = c.youtubeClient.GetVideosDetails(ctx, youtubeService, ids)
+		return err
+	})
+	if detailsErr != nil {
+		log.Printf("[ERROR] Error getting video details: %s", detailsErr)
+		c.recordError(ctx, run, "", detailsErr)
+	}
+
+	for _, pending := range pendingVideos {
+		newVideo := database.YouTubeVideo{
+			Title:       pending.title,
+			ChannelID:   pending.channelID,
+			Thumbnail:   pending.thumbnail,
+			PublishedAt: pending.publishedAt,
+			URL:         fmt.Sprintf("https://www.youtube.com/watch?v=%s", pending.id),
+			ID:          pending.id,
+			ContentType: database.VideoContentTypeVideo,
+		}
+
+		video, ok := details[pending.id]
+		switch {
+		case ok:
+			newVideo = applyVideoDetails(newVideo, video)
+			// Members-only uploads can still have details, which classify
+			// them as regular videos.
+			if pending.membersOnly && newVideo.ContentType == database.VideoContentTypeVideo {
+				newVideo.ContentType = database.VideoContentTypeMembersOnly
+			}
+		case detailsErr != nil:
+			continue
+		case pending.membersOnly:
+			newVideo.ContentType = database.VideoContentTypeMembersOnly
+		default:
+			// Deleted, private and region-blocked uploads have no details
+			// either. They are stored as unavailable so that they are neither
+			// shown nor looked up again.
+			if err := c.createUnavailableVideo(ctx, newVideo); err != nil {
+				c.recordError(ctx, run, newVideo.ChannelID, err)
+			}
+			continue
+		}
+
+		if err := c.youtubeRepository.CreateVideo(newVideo); err != nil {
+			c.recordError(ctx, run, newVideo.ChannelID, err)
+			continue
+		}
+
+		insertedVideos = append(insertedVideos, newVideo)
+		c.addInsertedVideo(ctx, run)
+	}
+
+	return insertedVideos, detailsErr
+}
+
+func (c *YouTubeProvider) createUnavailableVideo(ctx context.Context, video database.YouTubeVideo) error {
+	if err := c.youtubeRepository.CreateVideo(video); err != nil {
+		return err
+	}
+
+	return c.youtubeRepository.MarkVideoUnavailable(ctx, video.ID)
+}
+
+func (c *YouTubeProvider) unrecordedQuotaUnits() int64 {
+	return c.youtubeClient.QuotaUnits() - c.recordedQuotaUnits
+}
+
+// recordQuotaUsage stores the units the client spent since the previous
+// record, including calls made outside of sync runs, and returns them.
+func (c *YouTubeProvider) recordQuotaUsage(ctx context.Context) int64 {
+	quotaUnits := c.youtubeClient.QuotaUnits()
+	units := quotaUnits - c.recordedQuotaUnits
+	if units <= 0 {
+		return 0
+	}
+
+	err := c.youtubeSyncRepository.CreateQuotaUsage(ctx, database.YouTubeQuotaUsage{
+		Day:   quotaDay(time.Now()),
+		Units: units,
+	})
+	if err != nil {
+		return units
+	}
+
+	c.recordedQuotaUnits = quotaUnits
+	log.Printf("[INFO] YouTube sync spent about %d quota units", units)
+
+	return units
+}
+
+func (c *YouTubeProvider) recordError(ctx context.Context, run *database.SyncRun, channelID string, err error) {
+	runError := database.SyncRunError{
+		RunID:     run.ID,
+		ChannelID: channelID,
+		Message:   err.Error(),
+	}
+
+	if err := c.syncRunRepository.CreateError(ctx, runError); err != nil {
+		return
+	}
+
+	c.runMu.Lock()
+	run.Errors = append(run.Errors, runError)
+	c.runMu.Unlock()
+}
+
+func (c *YouTubeProvider) addScannedChannel(ctx context.Context, run *database.SyncRun) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	run.ChannelsScanned++
+	c.updateProgress(ctx, run)
+}
+
+func (c *YouTubeProvider) addInsertedVideo(ctx context.Context, run *database.SyncRun) {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	run.VideosInserted++
+	c.updateProgress(ctx, run)
+}
+
+// updateProgress saves the counters so that the run can be polled while it is
+// in progress, at most once per progressInterval. It must be called with runMu
+// held, which also keeps the updates in order.
+func (c *YouTubeProvider) updateProgress(ctx context.Context, run *database.SyncRun) {
+	if time.Since(c.lastProgressAt) < progressInterval {
+		return
+	}
+
+	c.lastProgressAt = time.Now()
+	_ = c.syncRunRepository.UpdateProgress(ctx, run.ID, run.ChannelsScanned, run.VideosInserted)
+}
+
+func (c *YouTubeProvider) finishRun(ctx context.Context, run *database.SyncRun, err error) {
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.QuotaUnits = c.recordQuotaUsage(ctx)
+	run.Status = database.SyncRunStatusCompleted
+
+	if err != nil {
+		run.Status = database.SyncRunStatusFailed
+		run.Error = err.Error()
+	}
+
+	// Cached feeds are dropped before the run is reported as finished, rather
+	// than on the published events, which can be dropped or handled late.
+	if c.feedCache != nil {
+		c.feedCache.Invalidate()
+	}
+
+	// The run is finished even when the sync context was canceled.
+	if err := c.syncRunRepository.Finish(context.WithoutCancel(ctx), *run); err != nil {
+		return
+	}
+
+	log.Printf("[INFO] Sync run %d %s: %d channels scanned, %d videos inserted, %d errors",
+		run.ID, run.Status, run.ChannelsScanned, run.VideosInserted, len(run.Errors))
+}
+
+// quotaDay returns the date the YouTube quota is counted against. The quota
+// resets at midnight Pacific time.
+func quotaDay(t time.Time) string {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		location = time.FixedZone("PST", -8*60*60)
+	}
+
+	return t.In(location).Format(time.DateOnly)
 }
 
 func (c *YouTubeProvider) prepareChannelsList(ctx context.Context, youtubeService *providers.Service) ([]string, error) {
@@ -154,59 +978,105 @@ func (c *YouTubeProvider) processRankingChannels() ([]string, error) {
 func (c *YouTubeProvider) processHistoryContent(ctx context.Context, youtubeService *providers.Service) ([]string, error) {
 	historyChannels := make([]string, 0)
 
-	historyContent, err := c.zimaClient.GetContent(false, YoutubeApplicationName)
+	historyContent, err := c.zimaClient.GetContent(ctx, false, YoutubeApplicationName)
 	if err != nil {
 		log.Printf("[ERROR] failed to get history content: %s", err)
 		return historyChannels, err
 	}
 
 	for _, content := range historyContent {
-		if content.Artist == "" || content.Artist == "Unknown" {
+		channel, err := c.resolveHistoryChannel(ctx, youtubeService, content)
+		if err != nil {
+			log.Printf("[ERROR] Error resolving history channel: %s", err)
 			continue
 		}
 
-		channel, err := c.youtubeRepository.GetChannelByTitle(content.Artist)
-		if err != nil {
-			log.Printf("[ERROR] Error getting channel by title: %s", err)
+		if channel == nil {
+			log.Printf("[WARN] Skipping history entry %q, its channel could not be resolved", content.Title)
 			continue
 		}
 
-		if channel == nil && content.Metadata != nil {
-			contentResp, err := c.youtubeClient.GetChannelByVideoId(youtubeService, content.Metadata.VideoID)
-			if err != nil {
-				log.Printf("[ERROR] Error getting channel by name: %s", err)
-				continue
-			}
+		if slices.Contains(historyChannels, channel.ID) {
+			continue
+		}
 
-			if contentResp == nil {
-				continue
-			}
+		historyChannels = append(historyChannels, channel.ID)
+	}
 
-			isSubscribed, err := c.youtubeClient.IsUserSubscribed(youtubeService, contentResp.Id)
-			if err != nil {
-				log.Printf("[ERROR] Error checking if user is subscribed: %s", err)
-			}
+	return historyChannels, nil
+}
 
-			channel, err = c.youtubeRepository.CreateChannel(&database.YouTubeChannel{
-				ID:           contentResp.Id,
-				Title:        strings.TrimSpace(contentResp.Snippet.Title),
-				PreviewURL:   contentResp.Snippet.Thumbnails.Medium.Url,
-				IsSubscribed: isSubscribed,
-			})
-			if err != nil {
-				log.Printf("[ERROR] Error creating channel: %s %s", contentResp.Snippet.Title, err)
-				continue
-			}
+// resolveHistoryChannel finds the channel of a watched video. The video ID is
+// tried first since display names are neither unique nor stable, the artist
+// name is only used when the entry has no video ID or it cannot be resolved.
+func (c *YouTubeProvider) resolveHistoryChannel(ctx context.Context, youtubeService *providers.Service, content providers.ZimaContent) (*database.YouTubeChannel, error) {
+	if content.Metadata != nil && content.Metadata.VideoID != "" {
+		channel, err := c.resolveChannelByVideoID(ctx, youtubeService, content.Metadata.VideoID)
+		if err != nil || channel != nil {
+			return channel, err
 		}
+	}
 
-		if channel == nil || slices.Contains(historyChannels, channel.ID) {
-			continue
+	if content.Artist == "" || content.Artist == "Unknown" {
+		return nil, nil
+	}
+
+	return c.youtubeRepository.GetChannelByTitle(content.Artist)
+}
+
+// resolveChannelByVideoID looks the video up in the stored videos and the
+// video to channel mapping, and only asks the Data API for unknown videos.
+// The answer is kept in the mapping, so each video is looked up once.
+func (c *YouTubeProvider) resolveChannelByVideoID(ctx context.Context, youtubeService *providers.Service, videoID string) (*database.YouTubeChannel, error) {
+	channelID, err := c.youtubeRepository.GetChannelIDByVideoID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if channelID != "" {
+		channel, err := c.youtubeRepository.GetChannelByID(channelID)
+		if err != nil || channel != nil {
+			return channel, err
 		}
+	}
 
-		historyChannels = append(historyChannels, channel.ID)
+	// Unknown videos can only be looked up through the Data API.
+	if youtubeService == nil {
+		return nil, nil
 	}
 
-	return historyChannels, nil
+	contentResp, err := c.youtubeClient.GetChannelByVideoId(youtubeService, videoID)
+	if err != nil || contentResp == nil {
+		return nil, err
+	}
+
+	channel, err := c.youtubeRepository.GetChannelByID(contentResp.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel == nil {
+		isSubscribed, err := c.youtubeClient.IsUserSubscribed(youtubeService, contentResp.Id)
+		if err != nil {
+			log.Printf("[ERROR] Error checking if user is subscribed: %s", err)
+		}
+
+		channel, err = c.youtubeRepository.CreateChannel(&database.YouTubeChannel{
+			ID:           contentResp.Id,
+			Title:        strings.TrimSpace(contentResp.Snippet.Title),
+			PreviewURL:   mediumThumbnail(contentResp.Snippet.Thumbnails),
+			IsSubscribed: isSubscribed,
+		})
+		if err != nil {
+			log.Printf("[ERROR] Error creating channel: %s %s", contentResp.Snippet.Title, err)
+			return nil, err
+		}
+	}
+
+	// The mapping only saves later lookups, the channel is resolved either way.
+	_ = c.youtubeRepository.SaveVideoChannel(ctx, videoID, channel.ID)
+
+	return channel, nil
 }
 
 func dedupeSlice[T comparable](sliceList []T) []T {
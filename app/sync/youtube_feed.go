@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const DefaultYouTubeFeedBaseURL = "https://www.youtube.com/feeds/videos.xml"
+
+// YouTubeFeed reads the public per-channel Atom feed. It needs neither an
+// OAuth token nor API quota, but it only lists the latest uploads and has no
+// video durations.
+type YouTubeFeed struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type YouTubeFeedOptions struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewYouTubeFeed(opt YouTubeFeedOptions) *YouTubeFeed {
+	baseURL := opt.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultYouTubeFeedBaseURL
+	}
+
+	httpClient := opt.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &YouTubeFeed{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+	}
+}
+
+type FeedStatusError struct {
+	ChannelID  string
+	StatusCode int
+}
+
+func (e *FeedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d for channel feed %s", e.StatusCode, e.ChannelID)
+}
+
+type FeedVideo struct {
+	ID          string
+	ChannelID   string
+	Title       string
+	Thumbnail   string
+	PublishedAt time.Time
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+	ChannelID string `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+	Title     string `xml:"title"`
+	Published string `xml:"published"`
+	Group     struct {
+		Thumbnail struct {
+			URL string `xml:"url,attr"`
+		} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// GetChannelVideos returns the uploads in the channel feed published after
+// publishedAfter.
+func (f *YouTubeFeed) GetChannelVideos(ctx context.Context, channelID string, publishedAfter time.Time) ([]FeedVideo, error) {
+	feedURL := fmt.Sprintf("%s?channel_id=%s", f.baseURL, url.QueryEscape(channelID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("[ERROR] failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &FeedStatusError{ChannelID: channelID, StatusCode: resp.StatusCode}
+	}
+
+	var feed atomFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	videos := make([]FeedVideo, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		publishedAt, err := time.Parse(time.RFC3339, entry.Published)
+		if err != nil {
+			log.Printf("[ERROR] Error parsing published at: %s", err)
+			continue
+		}
+
+		if entry.VideoID == "" || !publishedAt.After(publishedAfter) {
+			continue
+		}
+
+		videos = append(videos, FeedVideo{
+			ID:          entry.VideoID,
+			ChannelID:   entry.ChannelID,
+			Title:       entry.Title,
+			Thumbnail:   entry.Group.Thumbnail.URL,
+			PublishedAt: publishedAt,
+		})
+	}
+
+	return videos, nil
+}
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const channelFeedFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <title>Some Channel</title>
+ <yt:channelId>UCabc</yt:channelId>
+ <entry>
+  <id>yt:video:video3</id>
+  <yt:videoId>video3</yt:videoId>
+  <yt:channelId>UCabc</yt:channelId>
+  <title>Newest upload</title>
+  <published>2024-11-10T18:00:00+00:00</published>
+  <media:group>
+   <media:title>Newest upload</media:title>
+   <media:thumbnail url="https://i.ytimg.com/vi/video3/hqdefault.jpg" width="480" height="360"/>
+  </media:group>
+ </entry>
+ <entry>
+  <id>yt:video:video2</id>
+  <yt:videoId>video2</yt:videoId>
+  <yt:channelId>UCabc</yt:channelId>
+  <title>Older upload</title>
+  <published>2024-11-08T09:30:00+01:00</published>
+  <media:group>
+   <media:thumbnail url="https://i.ytimg.com/vi/video2/hqdefault.jpg" width="480" height="360"/>
+  </media:group>
+ </entry>
+ <entry>
+  <id>yt:video:video1</id>
+  <yt:videoId>video1</yt:videoId>
+  <yt:channelId>UCabc</yt:channelId>
+  <title>Already synced upload</title>
+  <published>2024-11-01T12:00:00+00:00</published>
+ </entry>
+</feed>`
+
+func TestYouTubeFeedGetChannelVideos(t *testing.T) {
+	var requestedChannel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedChannel = r.URL.Query().Get("channel_id")
+		w.Header().Set("Content-Type", "application/atom+xml")
+		_, _ = w.Write([]byte(channelFeedFixture))
+	}))
+	defer server.Close()
+
+	feed := NewYouTubeFeed(YouTubeFeedOptions{BaseURL: server.URL})
+	publishedAfter := time.Date(2024, 11, 5, 0, 0, 0, 0, time.UTC)
+
+	videos, err := feed.GetChannelVideos(context.Background(), "UCabc", publishedAfter)
+	if err != nil {
+		t.Fatalf("GetChannelVideos() error = %v", err)
+	}
+
+	if requestedChannel != "UCabc" {
+		t.Errorf("requested channel_id = %q, want %q", requestedChannel, "UCabc")
+	}
+
+	want := []FeedVideo{
+		{
+			ID:          "video3",
+			ChannelID:   "UCabc",
+			Title:       "Newest upload",
+			Thumbnail:   "https://i.ytimg.com/vi/video3/hqdefault.jpg",
+			PublishedAt: time.Date(2024, 11, 10, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:          "video2",
+			ChannelID:   "UCabc",
+			Title:       "Older upload",
+			Thumbnail:   "https://i.ytimg.com/vi/video2/hqdefault.jpg",
+			PublishedAt: time.Date(2024, 11, 8, 8, 30, 0, 0, time.UTC),
+		},
+	}
+
+	if len(videos) != len(want) {
+		t.Fatalf("got %d videos, want %d: %+v", len(videos), len(want), videos)
+	}
+
+	for i, video := range videos {
+		if video.ID != want[i].ID || video.ChannelID != want[i].ChannelID || video.Title != want[i].Title || video.Thumbnail != want[i].Thumbnail {
+			t.Errorf("video %d = %+v, want %+v", i, video, want[i])
+		}
+
+		if !video.PublishedAt.Equal(want[i].PublishedAt) {
+			t.Errorf("video %d published at %s, want %s", i, video.PublishedAt, want[i].PublishedAt)
+		}
+	}
+}
+
+func TestYouTubeFeedGetChannelVideosStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	feed := NewYouTubeFeed(YouTubeFeedOptions{BaseURL: server.URL})
+
+	_, err := feed.GetChannelVideos(context.Background(), "UCmissing", time.Time{})
+
+	feedErr, ok := err.(*FeedStatusError)
+	if !ok {
+		t.Fatalf("GetChannelVideos() error = %v, want *FeedStatusError", err)
+	}
+
+	if feedErr.StatusCode != http.StatusNotFound || feedErr.ChannelID != "UCmissing" {
+		t.Errorf("FeedStatusError = %+v", feedErr)
+	}
+}
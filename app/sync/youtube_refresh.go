@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"content-oracle/app/database"
+	"content-oracle/app/events"
+	"content-oracle/app/providers"
+	"context"
+	"errors"
+	"google.golang.org/api/youtube/v3"
+	"log"
+	"time"
+)
+
+type YouTubeVideosUpdate struct {
+	Updated     []database.YouTubeVideo `json:"updated"`
+	Unavailable []string                `json:"unavailable"`
+}
+
+// Refresh re-checks the metadata of recent videos and records it as a sync
+// run. Renamed videos get their new title and thumbnail, ended broadcasts
+// become regular videos and deleted or private ones are marked unavailable
+// until they can be loaded again. Videos synced from channel feeds get their
+// details and are shown from then on. While another run is in progress, the
+// refresh is queued and starts once that run ends.
+func (c *YouTubeProvider) Refresh(ctx context.Context) error {
+	opt := SyncOptions{TriggeredBy: database.SyncRunTriggerSchedule}
+
+	run, err := c.startRun(ctx, SyncRunSourceYouTubeRefresh, opt)
+	if errors.Is(err, ErrSyncRunning) {
+		c.refreshQueued.Store(true)
+		// The run may have ended before the refresh was queued.
+		run, err = c.startRun(ctx, SyncRunSourceYouTubeRefresh, opt)
+		if errors.Is(err, ErrSyncRunning) {
+			log.Printf("[INFO] Queued YouTube refresh: %s", err)
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.refreshQueued.Store(false)
+	defer c.endRun(ctx)
+
+	err = c.refresh(ctx, run)
+	c.finishRun(ctx, run, err)
+
+	return err
+}
+
+func (c *YouTubeProvider) refresh(ctx context.Context, run *database.SyncRun) error {
+	spentToday, err := c.youtubeSyncRepository.GetQuotaUnits(ctx, quotaDay(time.Now()))
+	if err != nil {
+		log.Printf("[ERROR] failed to get spent quota: %s", err)
+		return err
+	}
+
+	// Channel feeds cannot tell a deleted video from an old one, so the
+	// refresh needs the Data API.
+	if !c.hasAuthToken() {
+		log.Printf("[WARN] YouTube is not authorized, skipping refresh")
+		return nil
+	}
+
+	if spentToday+c.unrecordedQuotaUnits() >= c.dailyQuota {
+		log.Printf("[WARN] YouTube quota budget of %d units is used up, skipping refresh", c.dailyQuota)
+		return nil
+	}
+
+	youtubeService, err := c.youtubeClient.GetService(ctx)
+	if err != nil {
+		log.Printf("[ERROR] failed to get youtube service: %s", err)
+		return err
+	}
+
+	videos, err := c.youtubeRepository.GetVideosToRefresh(ctx, time.Now().Add(-c.refreshLookback), c.refreshLimit)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(videos))
+	for _, video := range videos {
+		ids = append(ids, video.ID)
+	}
+
+	var details map[string]*youtube.Video
+	err = c.retry(ctx, func() error {
+		var err error
+		details, err = c.youtubeClient.GetVideosDetails(ctx, youtubeService, ids)
+		return err
+	})
+	if err != nil {
+		// Missing details would mark videos unavailable by mistake.
+		log.Printf("[ERROR] Error getting video details: %s", err)
+		return err
+	}
+
+	update := YouTubeVideosUpdate{
+		Updated:     make([]database.YouTubeVideo, 0),
+		Unavailable: make([]string, 0),
+	}
+	// Pending and unavailable videos are not shown, so once they load they
+	// are published as new ones.
+	added := make([]database.YouTubeVideo, 0)
+
+	for _, video := range videos {
+		videoDetails, ok := details[video.ID]
+		if !ok {
+			if err := c.youtubeRepository.MarkVideoUnavailable(ctx, video.ID); err != nil {
+				c.recordError(ctx, run, video.ChannelID, err)
+				continue
+			}
+
+			if video.IsAvailable {
+				update.Unavailable = append(update.Unavailable, video.ID)
+			}
+			continue
+		}
+
+		refreshed := applyVideoDetails(video, videoDetails)
+		if err := c.youtubeRepository.UpdateVideo(ctx, refreshed); err != nil {
+			c.recordError(ctx, run, video.ChannelID, err)
+			continue
+		}
+
+		if video.ContentType == database.VideoContentTypePending || !video.IsAvailable {
+			added = append(added, refreshed)
+			continue
+		}
+
+		if isVideoChanged(video, refreshed) {
+			update.Updated = append(update.Updated, refreshed)
+		}
+	}
+
+	log.Printf("[INFO] Refreshed %d YouTube videos: %d added, %d changed, %d unavailable",
+		len(videos), len(added), len(update.Updated), len(update.Unavailable))
+
+	if c.eventBroker == nil {
+		return nil
+	}
+
+	if len(added) > 0 {
+		c.eventBroker.Publish(events.TypeYouTubeVideosAdded, c.videosToContent(added))
+	}
+
+	if len(update.Updated) > 0 || len(update.Unavailable) > 0 {
+		c.eventBroker.Publish(events.TypeYouTubeVideosUpdated, update)
+	}
+
+	return nil
+}
+
+// applyVideoDetails copies the metadata loaded from the Data API to video.
+func applyVideoDetails(video database.YouTubeVideo, details *youtube.Video) database.YouTubeVideo {
+	if details.Snippet != nil {
+		video.Title = details.Snippet.Title
+		if thumbnail := mediumThumbnail(details.Snippet.Thumbnails); thumbnail != "" {
+			video.Thumbnail = thumbnail
+		}
+	}
+
+	video.ContentType, video.ScheduledStartAt = providers.ClassifyVideo(details)
+	if video.ContentType == database.VideoContentTypeVideo && details.ContentDetails != nil {
+		duration, _ := providers.ParseVideoDuration(details.ContentDetails.Duration)
+		video.IsShorts = providers.IsShortVideo(duration)
+		video.Duration = int(duration.Seconds())
+	}
+
+	return video
+}
+
+func isVideoChanged(old, refreshed database.YouTubeVideo) bool {
+	if old.Title != refreshed.Title || old.Thumbnail != refreshed.Thumbnail ||
+		old.ContentType != refreshed.ContentType || old.Duration != refreshed.Duration {
+		return true
+	}
+
+	if old.ScheduledStartAt == nil || refreshed.ScheduledStartAt == nil {
+		return old.ScheduledStartAt != refreshed.ScheduledStartAt
+	}
+
+	return !old.ScheduledStartAt.Equal(*refreshed.ScheduledStartAt)
+}
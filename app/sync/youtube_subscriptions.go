@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"content-oracle/app/database"
+	"content-oracle/app/events"
+	"content-oracle/app/providers"
+	"context"
+	"google.golang.org/api/youtube/v3"
+	"log"
+	"strings"
+)
+
+// SubscriptionsDiff lists the channels whose subscription state changed.
+// Resubscribed channels count as added.
+type SubscriptionsDiff struct {
+	Added        []database.YouTubeChannel `json:"added"`
+	Unsubscribed []database.YouTubeChannel `json:"unsubscribed"`
+	Updated      []database.YouTubeChannel `json:"updated"`
+}
+
+func (d SubscriptionsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Unsubscribed) == 0 && len(d.Updated) == 0
+}
+
+// ReconcileSubscriptions reconciles the subscriptions outside of a sync run.
+// Full runs reconcile them too, so like Start it fails with ErrSyncRunning
+// while a run is in progress.
+func (c *YouTubeProvider) ReconcileSubscriptions(ctx context.Context, youtubeService *providers.Service) (SubscriptionsDiff, error) {
+	if !c.running.CompareAndSwap(false, true) {
+		return SubscriptionsDiff{}, ErrSyncRunning
+	}
+	defer c.endRun(ctx)
+
+	return c.reconcileSubscriptions(ctx, youtubeService)
+}
+
+// reconcileSubscriptions makes the stored channels match the subscriptions of
+// the user on YouTube. New subscriptions are added, channels the user
+// unsubscribed from are marked as such and titles and preview images are
+// refreshed.
+func (c *YouTubeProvider) reconcileSubscriptions(ctx context.Context, youtubeService *providers.Service) (SubscriptionsDiff, error) {
+	diff := SubscriptionsDiff{
+		Added:        make([]database.YouTubeChannel, 0),
+		Unsubscribed: make([]database.YouTubeChannel, 0),
+		Updated:      make([]database.YouTubeChannel, 0),
+	}
+
+	var subscriptions []*youtube.Subscription
+	err := c.retry(ctx, func() error {
+		var err error
+		subscriptions, err = c.youtubeClient.GetUserSubscriptions(ctx, youtubeService)
+		return err
+	})
+	if err != nil {
+		log.Printf("[ERROR] failed to get user subscriptions: %s", err)
+		return diff, err
+	}
+
+	subscribedChannels, err := c.youtubeRepository.GetAllSubscribedChannels()
+	if err != nil {
+		return diff, err
+	}
+
+	subscribed := make(map[string]bool, len(subscriptions))
+	for _, subscription := range subscriptions {
+		channelID := subscription.Snippet.ResourceId.ChannelId
+		subscribed[channelID] = true
+
+		title := strings.TrimSpace(subscription.Snippet.Title)
+		previewURL := mediumThumbnail(subscription.Snippet.Thumbnails)
+
+		channel, err := c.youtubeRepository.GetChannelByID(channelID)
+		if err != nil {
+			continue
+		}
+
+		if channel == nil {
+			channel, err = c.youtubeRepository.CreateChannel(&database.YouTubeChannel{
+				ID:           channelID,
+				Title:        title,
+				PreviewURL:   previewURL,
+				IsSubscribed: true,
+			})
+			if err != nil {
+				log.Printf("[ERROR] Error creating channel: %s %s", title, err)
+				continue
+			}
+
+			diff.Added = append(diff.Added, *channel)
+			continue
+		}
+
+		isResubscribed := !channel.IsSubscribed
+		isUpdated := channel.Title != title || (previewURL != "" && channel.PreviewURL != previewURL)
+		if !isResubscribed && !isUpdated {
+			continue
+		}
+
+		channel.Title = title
+		channel.IsSubscribed = true
+		if previewURL != "" {
+			channel.PreviewURL = previewURL
+		}
+
+		if err := c.youtubeRepository.UpdateChannel(ctx, *channel); err != nil {
+			continue
+		}
+
+		if isResubscribed {
+			diff.Added = append(diff.Added, *channel)
+		} else {
+			diff.Updated = append(diff.Updated, *channel)
+		}
+	}
+
+	for _, channel := range subscribedChannels {
+		if subscribed[channel.ID] {
+			continue
+		}
+
+		channel.IsSubscribed = false
+		if err := c.youtubeRepository.UpdateChannel(ctx, channel); err != nil {
+			continue
+		}
+
+		diff.Unsubscribed = append(diff.Unsubscribed, channel)
+	}
+
+	log.Printf("[INFO] Reconciled YouTube subscriptions: %d added, %d unsubscribed, %d updated",
+		len(diff.Added), len(diff.Unsubscribed), len(diff.Updated))
+
+	if !diff.IsEmpty() && c.eventBroker != nil {
+		c.eventBroker.Publish(events.TypeSubscriptionsChanged, diff)
+	}
+
+	return diff, nil
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"content-oracle/app/providers"
+	"context"
 	"fmt"
 	"log"
 	"sort"
@@ -47,8 +48,8 @@ type FullHistory struct {
 	Playback []Playback `json:"playback"`
 }
 
-func (p *History) GetAll() (*FullHistory, error) {
-	fullHistory, err := p.zimaClient.GetContent(true, "")
+func (p *History) GetAll(ctx context.Context) (*FullHistory, error) {
+	fullHistory, err := p.zimaClient.GetContent(ctx, true, "")
 	if err != nil {
 		log.Printf("[ERROR] failed to get youtube history: %s", err)
 		return nil, err
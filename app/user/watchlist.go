@@ -53,6 +53,14 @@ func (y *Watchlist) Add(videoID string) (*database.YouTubeWatchlist, error) {
 		publishedAt = time.Now()
 	}
 
+	var duration time.Duration
+	if video.ContentDetails != nil {
+		duration, err = providers.ParseVideoDuration(video.ContentDetails.Duration)
+		if err != nil {
+			log.Printf("[WARN] Unable to parse duration: %v", err)
+		}
+	}
+
 	channel, err := y.youtubeRepository.GetChannelByID(video.Snippet.ChannelId)
 	if err != nil {
 		return nil, err
@@ -83,6 +91,7 @@ func (y *Watchlist) Add(videoID string) (*database.YouTubeWatchlist, error) {
 		PublishedAt: publishedAt,
 		SyncAt:      time.Now().Local().String(),
 		IsShorts:    false,
+		Duration:    int(duration.Seconds()),
 	})
 
 	return watchlistItem, nil
@@ -0,0 +1,6 @@
+
+-- +migrate Up
+ALTER TABLE youtube_video ADD COLUMN duration INTEGER DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE youtube_video DROP COLUMN duration;
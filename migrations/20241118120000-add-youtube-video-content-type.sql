@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE youtube_video ADD COLUMN content_type TEXT DEFAULT 'video';
+ALTER TABLE youtube_video ADD COLUMN scheduled_start_at TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE youtube_video DROP COLUMN scheduled_start_at;
+ALTER TABLE youtube_video DROP COLUMN content_type;
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE youtube_video ADD COLUMN is_available BOOLEAN DEFAULT TRUE;
+ALTER TABLE youtube_video ADD COLUMN refreshed_at TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE youtube_video DROP COLUMN refreshed_at;
+ALTER TABLE youtube_video DROP COLUMN is_available;
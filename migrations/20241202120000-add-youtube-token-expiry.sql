@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE settings ADD COLUMN youtube_token_expiry TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE settings DROP COLUMN youtube_token_expiry;
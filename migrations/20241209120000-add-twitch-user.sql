@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE settings ADD COLUMN twitch_user_id TEXT DEFAULT '';
+ALTER TABLE settings ADD COLUMN twitch_user_login TEXT DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE settings DROP COLUMN twitch_user_login;
+ALTER TABLE settings DROP COLUMN twitch_user_id;